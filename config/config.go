@@ -0,0 +1,532 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"audio-converter/services"
+	"audio-converter/services/silk"
+	"audio-converter/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile 未指定配置文件时，若当前目录存在该文件则自动加载
+const DefaultFile = "config.yaml"
+
+// Config 服务配置
+// 加载顺序：默认值 < 配置文件 < 环境变量 < 命令行参数
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Log        LogConfig        `yaml:"log"`
+	Storage    StorageConfig    `yaml:"storage"`
+	Tools      ToolsConfig      `yaml:"tools"`
+	Limits     LimitsConfig     `yaml:"limits"`
+	Fetch      FetchConfig      `yaml:"fetch"`
+	Silk       SilkConfig       `yaml:"silk"`
+	TTS        TTSConfig        `yaml:"tts"`
+	Transcribe TranscribeConfig `yaml:"transcribe"`
+
+	File string `yaml:"-"` // 实际加载的配置文件，未加载时为空
+}
+
+// ServerConfig HTTP服务配置
+type ServerConfig struct {
+	Port  string `yaml:"port"`
+	Debug bool   `yaml:"debug"`
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Dir       string        `yaml:"dir"`
+	Level     string        `yaml:"level"` // DEBUG/INFO/WARN/ERROR 或 0~3
+	Color     bool          `yaml:"color"`
+	Retention time.Duration `yaml:"retention"` // 日志文件保留时间
+}
+
+// StorageConfig 目录与文件保留配置
+type StorageConfig struct {
+	UploadDir     string        `yaml:"upload_dir"`
+	OutputDir     string        `yaml:"output_dir"`
+	ImportDir     string        `yaml:"import_dir"`     // 允许 server_file 输入的目录，为空时禁用
+	Retention     time.Duration `yaml:"retention"`      // 上传和输出文件保留时间
+	CleanInterval time.Duration `yaml:"clean_interval"` // 清理任务执行间隔
+	Cache         bool          `yaml:"cache"`          // 是否启用转换结果缓存
+}
+
+// ToolsConfig 外部工具路径，为空时在PATH和默认位置中查找
+type ToolsConfig struct {
+	FFmpeg  string `yaml:"ffmpeg"`
+	FFprobe string `yaml:"ffprobe"`
+	Encoder string `yaml:"encoder"`
+	Decoder string `yaml:"decoder"`
+	Sox     string `yaml:"sox"`
+}
+
+// LimitsConfig 并发与超时配置
+type LimitsConfig struct {
+	Workers            int           `yaml:"workers"`
+	MaxQueue           int           `yaml:"max_queue"`
+	RetryAfter         int           `yaml:"retry_after"`
+	JobWorkers         int           `yaml:"job_workers"`
+	JobQueue           int           `yaml:"job_queue"`
+	MaxUploadSize      int64         `yaml:"max_upload_size"`      // 上传文件大小上限（字节）
+	MaxMultipartMemory int64         `yaml:"max_multipart_memory"` // multipart表单在内存中保留的上限，超出部分写入临时文件
+	MaxJSONBody        int64         `yaml:"max_json_body"`        // JSON请求体大小上限（字节）
+	DownloadTimeout    time.Duration `yaml:"download_timeout"`
+	DecodeTimeout      time.Duration `yaml:"decode_timeout"`
+	EncodeTimeout      time.Duration `yaml:"encode_timeout"`
+	ProbeTimeout       time.Duration `yaml:"probe_timeout"`
+}
+
+// FetchConfig URL下载策略配置
+type FetchConfig struct {
+	Allow          []string      `yaml:"allow"`
+	Deny           []string      `yaml:"deny"`
+	AllowPrivate   bool          `yaml:"allow_private"`
+	MaxBytes       int64         `yaml:"max_bytes"`
+	MaxRedirects   int           `yaml:"max_redirects"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	ReadTimeout    time.Duration `yaml:"read_timeout"`
+}
+
+// SilkConfig SILK编码参数
+type SilkConfig struct {
+	SampleRate int `yaml:"sample_rate"` // 编码器输入PCM采样率
+}
+
+// TTSConfig 文本转语音配置，各引擎按需启用
+type TTSConfig struct {
+	Provider    string        `yaml:"provider"`     // 默认引擎: espeak-ng/piper/openai，为空时使用第一个可用的引擎
+	Espeak      string        `yaml:"espeak"`       // espeak-ng路径，为空时在PATH中查找，找不到时不启用
+	EspeakVoice string        `yaml:"espeak_voice"` // espeak-ng默认发音人
+	Piper       string        `yaml:"piper"`        // piper路径，为空时在PATH中查找
+	PiperModel  string        `yaml:"piper_model"`  // piper模型文件(.onnx)，为空时不启用piper
+	URL         string        `yaml:"url"`          // OpenAI兼容服务地址，为空时不启用
+	APIKey      string        `yaml:"api_key"`
+	Model       string        `yaml:"model"`
+	Voice       string        `yaml:"voice"` // OpenAI兼容服务的默认发音人
+	MaxChars    int           `yaml:"max_chars"`
+	ChunkChars  int           `yaml:"chunk_chars"` // 单次提交给引擎的文本长度上限，长文本按句子切分
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// TranscribeConfig 语音识别配置，设置模型后启用
+type TranscribeConfig struct {
+	Whisper  string        `yaml:"whisper"`  // whisper.cpp命令行程序路径，为空时在PATH中查找
+	Model    string        `yaml:"model"`    // ggml模型文件，为空时不启用语音识别
+	Threads  int           `yaml:"threads"`  // 识别线程数，0表示使用whisper.cpp的默认值
+	Language string        `yaml:"language"` // 默认语言，为空或auto时自动检测
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	fetch := services.DefaultFetchPolicy()
+	return &Config{
+		Server: ServerConfig{Port: "8080", Debug: true},
+		Log: LogConfig{
+			Dir:       "./logs",
+			Level:     utils.LevelNames[utils.LevelDebug],
+			Color:     true,
+			Retention: 7 * 24 * time.Hour,
+		},
+		Storage: StorageConfig{
+			UploadDir:     "./uploads",
+			OutputDir:     "./outputs",
+			Retention:     24 * time.Hour,
+			CleanInterval: time.Hour,
+			Cache:         true,
+		},
+		Limits: LimitsConfig{
+			Workers:            runtime.NumCPU(),
+			MaxQueue:           32,
+			RetryAfter:         5,
+			JobWorkers:         2,
+			JobQueue:           100,
+			MaxUploadSize:      10 << 20,
+			MaxMultipartMemory: 8 << 20,
+			MaxJSONBody:        1 << 20,
+			DownloadTimeout:    60 * time.Second,
+			DecodeTimeout:      5 * time.Minute,
+			EncodeTimeout:      5 * time.Minute,
+			ProbeTimeout:       30 * time.Second,
+		},
+		Fetch: FetchConfig{
+			MaxBytes:       fetch.MaxBytes,
+			MaxRedirects:   fetch.MaxRedirects,
+			ConnectTimeout: fetch.ConnectTimeout,
+			ReadTimeout:    fetch.ReadTimeout,
+		},
+		Silk: SilkConfig{
+			SampleRate: 24000,
+		},
+		TTS: TTSConfig{
+			EspeakVoice: "cmn",
+			Model:       "tts-1",
+			Voice:       "alloy",
+			MaxChars:    services.DefaultMaxTTSChars,
+			ChunkChars:  services.DefaultTTSChunkChars,
+			Timeout:     2 * time.Minute,
+		},
+		Transcribe: TranscribeConfig{
+			Timeout: 5 * time.Minute,
+		},
+	}
+}
+
+// option 一个配置项对应的命令行参数和环境变量
+type option struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) interface{} // 返回配置项字段的指针
+}
+
+// options 所有可通过命令行参数和环境变量覆盖的配置项
+var options = []option{
+	{"port", "PORT", "服务器端口", func(c *Config) interface{} { return &c.Server.Port }},
+	{"debug", "DEBUG", "是否开启调试模式", func(c *Config) interface{} { return &c.Server.Debug }},
+
+	{"log-dir", "LOG_DIR", "日志目录", func(c *Config) interface{} { return &c.Log.Dir }},
+	{"log-level", "LOG_LEVEL", "日志级别: DEBUG/INFO/WARN/ERROR 或 0~3", func(c *Config) interface{} { return &c.Log.Level }},
+	{"log-color", "LOG_COLOR", "是否启用彩色日志输出", func(c *Config) interface{} { return &c.Log.Color }},
+	{"log-retention", "LOG_RETENTION", "日志文件保留时间", func(c *Config) interface{} { return &c.Log.Retention }},
+
+	{"upload-dir", "UPLOAD_DIR", "上传文件目录", func(c *Config) interface{} { return &c.Storage.UploadDir }},
+	{"output-dir", "OUTPUT_DIR", "输出文件目录", func(c *Config) interface{} { return &c.Storage.OutputDir }},
+	{"import-dir", "IMPORT_DIR", "允许通过 server_file 参数转换的服务器本地文件目录，为空时禁用", func(c *Config) interface{} { return &c.Storage.ImportDir }},
+	{"retention", "RETENTION", "上传和输出文件的保留时间", func(c *Config) interface{} { return &c.Storage.Retention }},
+	{"clean-interval", "CLEAN_INTERVAL", "过期文件清理间隔", func(c *Config) interface{} { return &c.Storage.CleanInterval }},
+	{"cache", "CACHE", "是否启用转换结果缓存（相同内容和参数直接返回已有结果）", func(c *Config) interface{} { return &c.Storage.Cache }},
+
+	{"ffmpeg", "FFMPEG_PATH", "ffmpeg路径，为空时自动查找", func(c *Config) interface{} { return &c.Tools.FFmpeg }},
+	{"ffprobe", "FFPROBE_PATH", "ffprobe路径，为空时在ffmpeg所在目录和PATH中查找", func(c *Config) interface{} { return &c.Tools.FFprobe }},
+	{"encoder", "ENCODER_PATH", "SILK encoder路径，为空时自动查找", func(c *Config) interface{} { return &c.Tools.Encoder }},
+	{"decoder", "DECODER_PATH", "SILK decoder路径，为空时自动查找", func(c *Config) interface{} { return &c.Tools.Decoder }},
+	{"sox", "SOX_PATH", "sox路径，为空时自动查找", func(c *Config) interface{} { return &c.Tools.Sox }},
+
+	{"workers", "WORKERS", "同时运行的转换数量上限", func(c *Config) interface{} { return &c.Limits.Workers }},
+	{"max-queue", "MAX_QUEUE", "同步转换请求的最大等待数量，超出后返回429", func(c *Config) interface{} { return &c.Limits.MaxQueue }},
+	{"retry-after", "RETRY_AFTER", "服务繁忙时建议客户端重试的间隔（秒）", func(c *Config) interface{} { return &c.Limits.RetryAfter }},
+	{"job-workers", "JOB_WORKERS", "异步任务工作协程数", func(c *Config) interface{} { return &c.Limits.JobWorkers }},
+	{"job-queue", "JOB_QUEUE", "异步任务队列长度", func(c *Config) interface{} { return &c.Limits.JobQueue }},
+	{"max-upload-size", "MAX_UPLOAD_SIZE", "上传文件大小上限（字节），超出返回413", func(c *Config) interface{} { return &c.Limits.MaxUploadSize }},
+	{"max-multipart-memory", "MAX_MULTIPART_MEMORY", "multipart表单在内存中保留的上限（字节），超出部分写入临时文件", func(c *Config) interface{} { return &c.Limits.MaxMultipartMemory }},
+	{"max-json-body", "MAX_JSON_BODY", "JSON请求体大小上限（字节）", func(c *Config) interface{} { return &c.Limits.MaxJSONBody }},
+	{"download-timeout", "DOWNLOAD_TIMEOUT", "URL下载超时，0表示不限制", func(c *Config) interface{} { return &c.Limits.DownloadTimeout }},
+	{"decode-timeout", "DECODE_TIMEOUT", "解码阶段超时，0表示不限制", func(c *Config) interface{} { return &c.Limits.DecodeTimeout }},
+	{"encode-timeout", "ENCODE_TIMEOUT", "编码阶段超时，0表示不限制", func(c *Config) interface{} { return &c.Limits.EncodeTimeout }},
+	{"probe-timeout", "PROBE_TIMEOUT", "单次ffprobe读取音频信息的超时，0表示不限制", func(c *Config) interface{} { return &c.Limits.ProbeTimeout }},
+
+	{"fetch-allow", "FETCH_ALLOW", "URL下载允许的主机名或CIDR，逗号分隔，为空时允许所有公网地址", func(c *Config) interface{} { return &c.Fetch.Allow }},
+	{"fetch-deny", "FETCH_DENY", "URL下载禁止的主机名或CIDR，逗号分隔", func(c *Config) interface{} { return &c.Fetch.Deny }},
+	{"fetch-allow-private", "FETCH_ALLOW_PRIVATE", "是否允许URL下载访问内网、回环和链路本地地址", func(c *Config) interface{} { return &c.Fetch.AllowPrivate }},
+	{"fetch-max-bytes", "FETCH_MAX_BYTES", "URL下载文件大小上限（字节），0表示不限制", func(c *Config) interface{} { return &c.Fetch.MaxBytes }},
+	{"fetch-max-redirects", "FETCH_MAX_REDIRECTS", "URL下载最大重定向次数", func(c *Config) interface{} { return &c.Fetch.MaxRedirects }},
+	{"fetch-connect-timeout", "FETCH_CONNECT_TIMEOUT", "URL下载连接超时", func(c *Config) interface{} { return &c.Fetch.ConnectTimeout }},
+	{"fetch-read-timeout", "FETCH_READ_TIMEOUT", "URL下载等待响应及读取间隔超时", func(c *Config) interface{} { return &c.Fetch.ReadTimeout }},
+
+	{"sample-rate", "SILK_SAMPLE_RATE", "SILK编码采样率: 8000/12000/16000/24000", func(c *Config) interface{} { return &c.Silk.SampleRate }},
+
+	{"tts-provider", "TTS_PROVIDER", "默认TTS引擎: espeak-ng/piper/openai，为空时使用第一个可用的引擎", func(c *Config) interface{} { return &c.TTS.Provider }},
+	{"tts-espeak", "ESPEAK_PATH", "espeak-ng路径，为空时在PATH中查找", func(c *Config) interface{} { return &c.TTS.Espeak }},
+	{"tts-espeak-voice", "ESPEAK_VOICE", "espeak-ng默认发音人", func(c *Config) interface{} { return &c.TTS.EspeakVoice }},
+	{"tts-piper", "PIPER_PATH", "piper路径，为空时在PATH中查找", func(c *Config) interface{} { return &c.TTS.Piper }},
+	{"tts-piper-model", "PIPER_MODEL", "piper模型文件(.onnx)，为空时不启用piper", func(c *Config) interface{} { return &c.TTS.PiperModel }},
+	{"tts-url", "TTS_URL", "OpenAI兼容TTS服务地址，为空时不启用", func(c *Config) interface{} { return &c.TTS.URL }},
+	{"tts-api-key", "TTS_API_KEY", "OpenAI兼容TTS服务的API Key", func(c *Config) interface{} { return &c.TTS.APIKey }},
+	{"tts-model", "TTS_MODEL", "OpenAI兼容TTS服务的模型", func(c *Config) interface{} { return &c.TTS.Model }},
+	{"tts-voice", "TTS_VOICE", "OpenAI兼容TTS服务的默认发音人", func(c *Config) interface{} { return &c.TTS.Voice }},
+	{"tts-max-chars", "TTS_MAX_CHARS", "单次合成的文本长度上限（字符）", func(c *Config) interface{} { return &c.TTS.MaxChars }},
+	{"tts-chunk-chars", "TTS_CHUNK_CHARS", "长文本切分后每段的长度上限（字符）", func(c *Config) interface{} { return &c.TTS.ChunkChars }},
+	{"tts-timeout", "TTS_TIMEOUT", "语音合成超时，0表示不限制", func(c *Config) interface{} { return &c.TTS.Timeout }},
+
+	{"whisper", "WHISPER_PATH", "whisper.cpp命令行程序路径，为空时在PATH中查找", func(c *Config) interface{} { return &c.Transcribe.Whisper }},
+	{"whisper-model", "WHISPER_MODEL", "whisper.cpp模型文件(ggml)，为空时不启用语音识别", func(c *Config) interface{} { return &c.Transcribe.Model }},
+	{"whisper-threads", "WHISPER_THREADS", "语音识别线程数，0表示使用whisper.cpp的默认值", func(c *Config) interface{} { return &c.Transcribe.Threads }},
+	{"transcribe-language", "TRANSCRIBE_LANGUAGE", "语音识别默认语言，为空时自动检测", func(c *Config) interface{} { return &c.Transcribe.Language }},
+	{"transcribe-timeout", "TRANSCRIBE_TIMEOUT", "语音识别超时，0表示不限制", func(c *Config) interface{} { return &c.Transcribe.Timeout }},
+}
+
+// Flags 已注册的命令行参数，只有显式指定的参数才会覆盖配置
+type Flags struct {
+	fs     *flag.FlagSet
+	values *Config
+}
+
+// RegisterFlags 在fs上注册所有配置项对应的命令行参数，需在 fs.Parse 之前调用
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs, values: Default()}
+	for _, opt := range options {
+		usage := fmt.Sprintf("%s（环境变量 %s）", opt.usage, opt.env)
+		switch p := opt.field(f.values).(type) {
+		case *string:
+			fs.StringVar(p, opt.flag, *p, usage)
+		case *bool:
+			fs.BoolVar(p, opt.flag, *p, usage)
+		case *int:
+			fs.IntVar(p, opt.flag, *p, usage)
+		case *int64:
+			fs.Int64Var(p, opt.flag, *p, usage)
+		case *time.Duration:
+			fs.DurationVar(p, opt.flag, *p, usage)
+		case *[]string:
+			fs.Var((*listValue)(p), opt.flag, usage)
+		}
+	}
+	return f
+}
+
+// Load 加载配置：path为空时依次尝试环境变量 CONFIG_FILE 和当前目录下的 DefaultFile
+func Load(path string, flags *Flags) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); err == nil {
+			path = DefaultFile
+		}
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		cfg.File = path
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if flags != nil {
+		flags.apply(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile 读取YAML配置文件，未知字段视为错误以便发现拼写问题
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// applyEnv 使用环境变量覆盖配置
+func (c *Config) applyEnv() error {
+	for _, opt := range options {
+		value, ok := os.LookupEnv(opt.env)
+		if !ok {
+			continue
+		}
+		if err := setValue(opt.field(c), value); err != nil {
+			return fmt.Errorf("环境变量 %s 无效: %v", opt.env, err)
+		}
+	}
+	return nil
+}
+
+// apply 使用显式指定的命令行参数覆盖配置
+func (f *Flags) apply(c *Config) {
+	byName := make(map[string]option, len(options))
+	for _, opt := range options {
+		byName[opt.flag] = opt
+	}
+	f.fs.Visit(func(fl *flag.Flag) {
+		opt, ok := byName[fl.Name]
+		if !ok {
+			return
+		}
+		switch dst := opt.field(c).(type) {
+		case *string:
+			*dst = *opt.field(f.values).(*string)
+		case *bool:
+			*dst = *opt.field(f.values).(*bool)
+		case *int:
+			*dst = *opt.field(f.values).(*int)
+		case *int64:
+			*dst = *opt.field(f.values).(*int64)
+		case *time.Duration:
+			*dst = *opt.field(f.values).(*time.Duration)
+		case *[]string:
+			*dst = *opt.field(f.values).(*[]string)
+		}
+	})
+}
+
+// setValue 将字符串解析后写入字段指针
+func setValue(field interface{}, value string) error {
+	switch p := field.(type) {
+	case *string:
+		*p = value
+	case *bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *int:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *int64:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *time.Duration:
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *[]string:
+		return (*listValue)(p).Set(value)
+	default:
+		return fmt.Errorf("不支持的配置类型 %T", field)
+	}
+	return nil
+}
+
+// Validate 检查配置取值，返回所有问题
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port 无效: %q", c.Server.Port)
+
+	_, err = utils.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level 无效: %q", c.Log.Level)
+	check(c.Log.Dir != "", "log.dir 不能为空")
+	check(c.Log.Retention > 0, "log.retention 必须大于0")
+
+	check(c.Storage.UploadDir != "", "storage.upload_dir 不能为空")
+	check(c.Storage.OutputDir != "", "storage.output_dir 不能为空")
+	check(c.Storage.UploadDir != c.Storage.OutputDir, "storage.upload_dir 与 storage.output_dir 不能相同")
+	check(c.Storage.Retention > 0, "storage.retention 必须大于0")
+	check(c.Storage.CleanInterval > 0, "storage.clean_interval 必须大于0")
+	if c.Storage.ImportDir != "" {
+		info, err := os.Stat(c.Storage.ImportDir)
+		check(err == nil && info.IsDir(), "storage.import_dir 不是有效目录: %s", c.Storage.ImportDir)
+	}
+
+	check(c.Limits.Workers >= 1, "limits.workers 必须大于等于1")
+	check(c.Limits.MaxQueue >= 0, "limits.max_queue 不能为负数")
+	check(c.Limits.RetryAfter >= 0, "limits.retry_after 不能为负数")
+	check(c.Limits.JobWorkers >= 1, "limits.job_workers 必须大于等于1")
+	check(c.Limits.JobQueue >= 0, "limits.job_queue 不能为负数")
+	check(c.Limits.MaxUploadSize > 0, "limits.max_upload_size 必须大于0")
+	check(c.Limits.MaxMultipartMemory > 0, "limits.max_multipart_memory 必须大于0")
+	check(c.Limits.MaxJSONBody > 0, "limits.max_json_body 必须大于0")
+	check(c.Limits.DownloadTimeout >= 0 && c.Limits.DecodeTimeout >= 0 && c.Limits.EncodeTimeout >= 0 && c.Limits.ProbeTimeout >= 0,
+		"limits 中的超时不能为负数")
+
+	check(c.Fetch.MaxBytes >= 0, "fetch.max_bytes 不能为负数")
+	check(c.Fetch.MaxRedirects >= 0, "fetch.max_redirects 不能为负数")
+	check(c.Fetch.ConnectTimeout >= 0 && c.Fetch.ReadTimeout >= 0, "fetch 中的超时不能为负数")
+	_, err = services.NewFetcher(c.FetchPolicy())
+	check(err == nil, "fetch 配置无效: %v", err)
+
+	check(silk.ValidateSampleRate(c.Silk.SampleRate) == nil, "silk.sample_rate 无效: %d", c.Silk.SampleRate)
+
+	switch c.TTS.Provider {
+	case "", "espeak-ng", "piper", "openai":
+	default:
+		check(false, "tts.provider 无效: %q", c.TTS.Provider)
+	}
+	check(c.TTS.Provider != "piper" || c.TTS.PiperModel != "", "tts.provider 为 piper 时必须设置 tts.piper_model")
+	check(c.TTS.Provider != "openai" || c.TTS.URL != "", "tts.provider 为 openai 时必须设置 tts.url")
+	if c.TTS.URL != "" {
+		u, err := url.Parse(c.TTS.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tts.url 无效: %q", c.TTS.URL)
+	}
+	check(c.TTS.MaxChars > 0, "tts.max_chars 必须大于0")
+	check(c.TTS.ChunkChars > 0, "tts.chunk_chars 必须大于0")
+	check(c.TTS.Timeout >= 0, "tts.timeout 不能为负数")
+
+	check(c.Transcribe.Threads >= 0, "transcribe.threads 不能为负数")
+	check(c.Transcribe.Timeout >= 0, "transcribe.timeout 不能为负数")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("配置无效: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// LogLevel 返回日志级别数值，调用前需已通过 Validate
+func (c *Config) LogLevel() int {
+	level, _ := utils.ParseLevel(c.Log.Level)
+	return level
+}
+
+// FetchPolicy 返回URL下载策略
+func (c *Config) FetchPolicy() services.FetchPolicy {
+	return services.FetchPolicy{
+		AllowHosts:     c.Fetch.Allow,
+		DenyHosts:      c.Fetch.Deny,
+		AllowPrivate:   c.Fetch.AllowPrivate,
+		MaxBytes:       c.Fetch.MaxBytes,
+		MaxRedirects:   c.Fetch.MaxRedirects,
+		ConnectTimeout: c.Fetch.ConnectTimeout,
+		ReadTimeout:    c.Fetch.ReadTimeout,
+	}
+}
+
+// YAML 以YAML格式输出配置，密钥以星号代替
+func (c *Config) YAML() ([]byte, error) {
+	masked := *c
+	if masked.TTS.APIKey != "" {
+		masked.TTS.APIKey = "******"
+	}
+	return yaml.Marshal(&masked)
+}
+
+// listValue 逗号分隔的字符串列表参数
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*l = items
+	return nil
+}
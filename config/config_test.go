@@ -0,0 +1,202 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig 将YAML内容写入临时配置文件
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// parseFlags 注册并解析命令行参数
+func parseFlags(t *testing.T, args ...string) *Flags {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestDefaultValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("默认配置无效: %v", err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: "9000"
+  debug: false
+storage:
+  retention: 2h
+limits:
+  workers: 3
+  job_workers: 5
+fetch:
+  allow: [example.com]
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("WORKERS", "4")
+	t.Setenv("FETCH_ALLOW", "a.com, b.com")
+	t.Setenv("DEBUG", "false")
+	// 显式指定的参数即使等于默认值也会覆盖环境变量
+	flags := parseFlags(t, "-port", "9200", "-debug=true")
+
+	cfg, err := Load(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.File != path {
+		t.Errorf("File = %q, want %q", cfg.File, path)
+	}
+	if cfg.Server.Port != "9200" {
+		t.Errorf("Server.Port = %q, 命令行参数应优先", cfg.Server.Port)
+	}
+	if !cfg.Server.Debug {
+		t.Error("Server.Debug = false, 显式指定的命令行参数应覆盖环境变量")
+	}
+	if cfg.Limits.Workers != 4 {
+		t.Errorf("Limits.Workers = %d, 环境变量应覆盖配置文件", cfg.Limits.Workers)
+	}
+	if want := []string{"a.com", "b.com"}; !reflect.DeepEqual(cfg.Fetch.Allow, want) {
+		t.Errorf("Fetch.Allow = %q, want %q", cfg.Fetch.Allow, want)
+	}
+	if cfg.Limits.JobWorkers != 5 || cfg.Storage.Retention != 2*time.Hour {
+		t.Errorf("配置文件中的值未生效: job_workers=%d retention=%s", cfg.Limits.JobWorkers, cfg.Storage.Retention)
+	}
+	if def := Default(); cfg.Limits.MaxQueue != def.Limits.MaxQueue || cfg.Storage.UploadDir != def.Storage.UploadDir {
+		t.Error("未设置的配置项应保持默认值")
+	}
+}
+
+func TestLoadConfigFileEnv(t *testing.T) {
+	path := writeConfig(t, "limits:\n  workers: 7\n")
+	t.Setenv("CONFIG_FILE", path)
+	cfg, err := Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.File != path || cfg.Limits.Workers != 7 {
+		t.Errorf("CONFIG_FILE 未生效: file=%q workers=%d", cfg.File, cfg.Limits.Workers)
+	}
+
+	// 空配置文件等同于全部使用默认值
+	path = writeConfig(t, "")
+	if _, err := Load(path, nil); err != nil {
+		t.Errorf("Load(空文件) = %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		want string
+	}{
+		{"未知字段", "limit:\n  workers: 2\n", nil, "limit"},
+		{"类型错误", "limits:\n  workers: many\n", nil, "many"},
+		{"无效的环境变量", "", map[string]string{"WORKERS": "abc"}, "WORKERS"},
+		{"无效的时长", "", map[string]string{"PROBE_TIMEOUT": "10"}, "PROBE_TIMEOUT"},
+		{"校验失败", "silk:\n  sample_rate: 44100\n", nil, "silk.sample_rate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(writeConfig(t, tt.yaml), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want 包含 %q 的错误", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), nil); err == nil {
+		t.Error("配置文件不存在时应返回错误")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"端口", func(c *Config) { c.Server.Port = "70000" }, "server.port"},
+		{"日志级别", func(c *Config) { c.Log.Level = "TRACE" }, "log.level"},
+		{"目录相同", func(c *Config) { c.Storage.OutputDir = c.Storage.UploadDir }, "storage.upload_dir"},
+		{"导入目录不存在", func(c *Config) { c.Storage.ImportDir = "/nonexistent/import" }, "storage.import_dir"},
+		{"并发数", func(c *Config) { c.Limits.Workers = 0 }, "limits.workers"},
+		{"上传上限", func(c *Config) { c.Limits.MaxUploadSize = 0 }, "limits.max_upload_size"},
+		{"负数超时", func(c *Config) { c.Limits.ProbeTimeout = -time.Second }, "超时不能为负数"},
+		{"无效的CIDR", func(c *Config) { c.Fetch.Allow = []string{"10.0.0.0/40"} }, "fetch 配置无效"},
+		{"采样率", func(c *Config) { c.Silk.SampleRate = 48000 }, "silk.sample_rate"},
+		{"TTS引擎", func(c *Config) { c.TTS.Provider = "say" }, "tts.provider"},
+		{"piper缺少模型", func(c *Config) { c.TTS.Provider = "piper" }, "tts.piper_model"},
+		{"openai缺少地址", func(c *Config) { c.TTS.Provider = "openai" }, "tts.url"},
+		{"TTS地址", func(c *Config) { c.TTS.URL = "ftp://tts.local" }, "tts.url"},
+		{"识别线程数", func(c *Config) { c.Transcribe.Threads = -1 }, "transcribe.threads"},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		tt.modify(cfg)
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Validate = %v, want 包含 %q 的错误", tt.name, err, tt.want)
+		}
+	}
+
+	// 一次返回所有问题
+	cfg := Default()
+	cfg.Server.Port = "0"
+	cfg.Limits.Workers = 0
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "server.port") || !strings.Contains(err.Error(), "limits.workers") {
+		t.Errorf("Validate = %v, 应同时报告所有问题", err)
+	}
+}
+
+func TestYAMLMasksAPIKey(t *testing.T) {
+	cfg := Default()
+	cfg.TTS.APIKey = "sk-secret"
+	data, err := cfg.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-secret") {
+		t.Error("YAML输出中不应包含API Key")
+	}
+	if cfg.TTS.APIKey != "sk-secret" {
+		t.Error("YAML不应修改原配置")
+	}
+}
+
+func TestListValue(t *testing.T) {
+	var l listValue
+	if err := l.Set(" a.com,,b.com , "); err != nil {
+		t.Fatal(err)
+	}
+	if want := (listValue{"a.com", "b.com"}); !reflect.DeepEqual(l, want) {
+		t.Errorf("Set = %q, want %q", l, want)
+	}
+	if l.String() != "a.com,b.com" {
+		t.Errorf("String = %q", l.String())
+	}
+}
@@ -1,77 +1,222 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"net"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"audio-converter/config"
 	"audio-converter/services"
+	"audio-converter/services/silk"
 	"audio-converter/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 var (
-	// 命令行参数
-	port     = flag.String("port", "8080", "服务器端口")
-	debug    = flag.Bool("debug", true, "是否开启调试模式")
-	logLevel = flag.Int("log-level", utils.LevelDebug, "日志级别: 0=DEBUG, 1=INFO, 2=WARN, 3=ERROR")
-	noColor  = flag.Bool("no-color", false, "禁用彩色日志输出")
-
-	// 目录配置
-	uploadDir = "./uploads"
-	silkDir   = "./outputs"
-	logsDir   = "./logs"
-	cacheTime = 24 * time.Hour // 缓存时间，默认24小时
+	// 命令行参数，其余配置项由 config.RegisterFlags 注册
+	configFile  = flag.String("config", "", "配置文件路径（YAML），默认读取环境变量 CONFIG_FILE 或当前目录下的 config.yaml")
+	printConfig = flag.Bool("print-config", false, "输出合并后的最终配置并退出")
+	noColor     = flag.Bool("no-color", false, "禁用彩色日志输出（等同于 -log-color=false）")
+	configFlags = config.RegisterFlags(flag.CommandLine)
+
+	// 生效的配置
+	cfg *config.Config
 
 	// 服务实例
 	audioService *services.AudioService
+	jobManager   *services.JobManager
 )
 
+// shutdownTimeout 关闭时等待异步任务退出的最长时间
+const shutdownTimeout = 30 * time.Second
+
+// 加载配置，配置无效时直接退出
+func loadConfig() {
+	var err error
+	cfg, err = config.Load(*configFile, configFlags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *noColor {
+		cfg.Log.Color = false
+	}
+
+	if *printConfig {
+		data, err := cfg.YAML()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "输出配置失败: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+		os.Exit(0)
+	}
+}
+
 // 初始化服务
 func initService() {
 	// 确保目录存在
-	os.MkdirAll(uploadDir, 0755)
-	os.MkdirAll(silkDir, 0755)
-	os.MkdirAll(logsDir, 0755)
+	os.MkdirAll(cfg.Storage.UploadDir, 0755)
+	os.MkdirAll(cfg.Storage.OutputDir, 0755)
+	os.MkdirAll(cfg.Log.Dir, 0755)
 
 	// 初始化日志
-	if err := utils.InitLogger(logsDir); err != nil {
+	if err := utils.InitLogger(cfg.Log.Dir); err != nil {
 		fmt.Printf("初始化日志失败: %v\n", err)
 		os.Exit(1)
 	}
 
 	// 设置日志级别
-	utils.SetLevel(*logLevel)
+	utils.SetLevel(cfg.LogLevel())
 
 	// 设置日志颜色
-	utils.EnableColor(!*noColor)
+	utils.EnableColor(cfg.Log.Color)
 
 	// 输出初始化信息
 	utils.Info("==== 音频转换服务初始化 ====")
-	utils.Info("调试模式: %v", *debug)
-	utils.Info("日志级别: %s", utils.LevelNames[*logLevel])
-	utils.Info("彩色日志: %v", !*noColor)
+	if cfg.File != "" {
+		utils.Info("配置文件: %s", cfg.File)
+	}
+	utils.Info("调试模式: %v", cfg.Server.Debug)
+	utils.Info("日志级别: %s", utils.LevelNames[cfg.LogLevel()])
+	utils.Info("彩色日志: %v", cfg.Log.Color)
 
 	// 创建音频服务实例
-	audioService = services.NewAudioService(uploadDir, silkDir)
+	audioService = services.NewAudioService(cfg.Storage.UploadDir, cfg.Storage.OutputDir, services.Tools{
+		FFmpeg:  cfg.Tools.FFmpeg,
+		FFprobe: cfg.Tools.FFprobe,
+		Encoder: cfg.Tools.Encoder,
+		Decoder: cfg.Tools.Decoder,
+		Sox:     cfg.Tools.Sox,
+	})
+	audioService.SampleRate = cfg.Silk.SampleRate
+	utils.Info("SILK编码采样率: %d", cfg.Silk.SampleRate)
+
+	// 服务器本地文件只能来自导入目录
+	if cfg.Storage.ImportDir != "" {
+		dir, err := filepath.Abs(cfg.Storage.ImportDir)
+		if err != nil {
+			utils.Fatal("导入目录无效: %v", err)
+		}
+		audioService.ImportDir = dir
+		utils.Info("导入目录: %s", dir)
+	}
+
+	// 限制同时运行的转换数量
+	limits := cfg.Limits
+	audioService.Limiter = services.NewLimiter(limits.Workers, limits.MaxQueue)
+	utils.Info("并发转换上限: %d, 最大等待数: %d", limits.Workers, limits.MaxQueue)
+
+	// 各阶段超时
+	audioService.DownloadTimeout = limits.DownloadTimeout
+	audioService.DecodeTimeout = limits.DecodeTimeout
+	audioService.EncodeTimeout = limits.EncodeTimeout
+	audioService.ProbeTimeout = limits.ProbeTimeout
+	utils.Info("阶段超时: 下载=%v, 解码=%v, 编码=%v, 读取信息=%v",
+		limits.DownloadTimeout, limits.DecodeTimeout, limits.EncodeTimeout, limits.ProbeTimeout)
+
+	// URL下载策略
+	fetcher, err := services.NewFetcher(cfg.FetchPolicy())
+	if err != nil {
+		utils.Fatal("初始化URL下载器失败: %v", err)
+	}
+	audioService.Fetcher = fetcher
+	utils.Info("URL下载策略: 允许=%q, 禁止=%q, 允许内网=%v, 大小上限=%d, 重定向上限=%d",
+		cfg.Fetch.Allow, cfg.Fetch.Deny, cfg.Fetch.AllowPrivate, cfg.Fetch.MaxBytes, cfg.Fetch.MaxRedirects)
+
+	// 转换结果缓存
+	if cfg.Storage.Cache {
+		audioService.Cache = services.NewCache(audioService.SilkDir)
+	}
+
+	// 文本转语音引擎
+	setupTTS(cfg.TTS)
+
+	// 语音识别引擎
+	setupTranscribe(cfg.Transcribe)
+
+	// 创建异步任务管理器
+	jobManager = services.NewJobManager(audioService, limits.JobWorkers, limits.JobQueue)
 
 	// 启动定时清理任务
 	go startCleaner()
 }
 
+// 按配置注册TTS引擎：espeak-ng在找到程序时启用，piper和OpenAI兼容服务在配置后启用
+func setupTTS(tts config.TTSConfig) {
+	registry := audioService.Registry
+	if path := findTool(tts.Espeak, "espeak-ng"); path != "" {
+		registry.RegisterTTS(&services.EspeakTTS{Path: path, Voice: tts.EspeakVoice})
+	}
+	if tts.PiperModel != "" {
+		path := findTool(tts.Piper, "piper")
+		if path == "" {
+			path = "piper"
+		}
+		registry.RegisterTTS(&services.PiperTTS{Path: path, Model: tts.PiperModel, TempDir: audioService.UploadDir})
+	}
+	if tts.URL != "" {
+		registry.RegisterTTS(services.NewOpenAITTS(tts.URL, tts.APIKey, tts.Model, tts.Voice, tts.Timeout))
+	}
+	audioService.DefaultTTS = tts.Provider
+	audioService.MaxTTSChars = tts.MaxChars
+	audioService.TTSChunkChars = tts.ChunkChars
+	audioService.TTSTimeout = tts.Timeout
+	utils.Info("TTS引擎: %v, 默认: %q", registry.TTSNames(), tts.Provider)
+}
+
+// 配置了模型时启用whisper.cpp语音识别，程序依次查找 whisper-cli 和旧版的 whisper
+func setupTranscribe(tc config.TranscribeConfig) {
+	audioService.TranscribeTimeout = tc.Timeout
+	if tc.Model == "" {
+		return
+	}
+	path := findTool(tc.Whisper, "whisper-cli")
+	if path == "" {
+		path = findTool("", "whisper")
+	}
+	if path == "" {
+		utils.Warn("未找到whisper.cpp程序，语音识别不可用")
+		return
+	}
+	language := tc.Language
+	if language == "auto" {
+		language = ""
+	}
+	audioService.Transcriber = &services.WhisperTranscriber{Path: path, Model: tc.Model, Threads: tc.Threads, Language: language}
+	utils.Info("语音识别: %s, 模型: %s", path, tc.Model)
+}
+
+// 返回配置的工具路径，未配置时在PATH中查找，找不到时返回空
+func findTool(configured, name string) string {
+	if configured != "" {
+		return configured
+	}
+	if p, err := exec.LookPath(name); err == nil {
+		return p
+	}
+	return ""
+}
+
 // 周期性清理临时文件
 func startCleaner() {
-	ticker := time.NewTicker(1 * time.Hour)
-	utils.Debug("启动文件清理定时任务，间隔: 1小时")
+	ticker := time.NewTicker(cfg.Storage.CleanInterval)
+	utils.Debug("启动文件清理定时任务，间隔: %v, 文件保留: %v", cfg.Storage.CleanInterval, cfg.Storage.Retention)
 
 	for range ticker.C {
 		cleanTempFiles()
@@ -81,9 +226,19 @@ func startCleaner() {
 // 清理临时文件
 func cleanTempFiles() {
 	utils.Debug("开始执行清理任务")
-	cleanDir(uploadDir)
-	cleanDir(silkDir)
-	utils.CleanOldLogs(logsDir)
+	cleanDir(audioService.UploadDir)
+	cleanDir(audioService.SilkDir)
+	if audioService != nil && audioService.Cache != nil {
+		if n := audioService.Cache.Prune(); n > 0 {
+			utils.Info("已清理%d条过期缓存记录", n)
+		}
+	}
+	if jobManager != nil {
+		if n := jobManager.Prune(cfg.Storage.Retention); n > 0 {
+			utils.Info("已清理%d条过期任务记录", n)
+		}
+	}
+	utils.CleanOldLogs(cfg.Log.Dir, cfg.Log.Retention)
 	utils.Debug("清理任务完成")
 }
 
@@ -100,20 +255,30 @@ func cleanDir(dir string) {
 	count := 0
 
 	for _, file := range files {
-		if file.IsDir() {
+		// 缓存索引随输出文件一起由 Cache.Prune 维护
+		if file.IsDir() || file.Name() == services.CacheIndexFile {
 			continue
 		}
 
 		path := filepath.Join(dir, file.Name())
+		// 元数据随输出文件一起删除，这里只清理失去输出文件的元数据
+		if output, ok := services.IsMetaFile(file.Name()); ok {
+			if _, err := os.Stat(filepath.Join(dir, output)); os.IsNotExist(err) {
+				os.Remove(path)
+			}
+			continue
+		}
+
 		info, err := file.Info()
 		if err != nil {
 			continue
 		}
 
-		if now.Sub(info.ModTime()) > cacheTime {
+		if now.Sub(info.ModTime()) > cfg.Storage.Retention {
 			if err := os.Remove(path); err != nil {
 				utils.Error("删除文件失败: %s: %v", path, err)
 			} else {
+				os.Remove(services.MetaPath(dir, file.Name()))
 				count++
 				utils.Debug("已删除过期文件: %s", path)
 			}
@@ -136,7 +301,11 @@ func handleUpload(c *gin.Context) {
 	clientIP := c.ClientIP()
 	utils.Info("收到文件上传请求: %s", clientIP)
 
-	file, err := c.FormFile("file")
+	file, err := formFile(c)
+	if errors.Is(err, errUploadTooLarge) {
+		respondTooLarge(c, cfg.Limits.MaxUploadSize)
+		return
+	}
 	if err != nil {
 		utils.Error("上传文件失败: %s: %v", clientIP, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "上传文件失败: " + err.Error()})
@@ -145,20 +314,15 @@ func handleUpload(c *gin.Context) {
 
 	utils.Info("上传文件: %s, 大小: %.2f KB", file.Filename, float64(file.Size)/1024)
 
-	// 打开上传的文件
-	src, err := file.Open()
-	if err != nil {
-		utils.Error("无法读取上传的文件: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法读取上传的文件: " + err.Error()})
+	var opts services.ConvertOptions
+	if !bindConvertForm(c, &opts) {
 		return
 	}
-	defer src.Close()
 
-	// 读取文件内容
-	content, err := io.ReadAll(src)
+	// 保存上传的文件
+	input, err := saveUpload(file)
 	if err != nil {
-		utils.Error("无法读取文件内容: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法读取文件内容: " + err.Error()})
+		c.JSON(convertErrorStatus(err), gin.H{"error": "无法读取上传的文件: " + err.Error()})
 		return
 	}
 
@@ -166,10 +330,15 @@ func handleUpload(c *gin.Context) {
 
 	// 调用音频转换服务
 	startTime := time.Now()
-	filename, err := audioService.ConvertToSilk(content)
+	opts.OriginalName = file.Filename
+	result, err := audioService.Convert(c.Request.Context(), input, opts)
+	if errors.Is(err, services.ErrServerBusy) {
+		respondBusy(c, http.StatusTooManyRequests, err)
+		return
+	}
 	if err != nil {
 		utils.Error("音频转换失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "音频转换失败: " + err.Error()})
+		c.JSON(convertErrorStatus(err), gin.H{"error": "音频转换失败: " + err.Error()})
 		return
 	}
 
@@ -177,20 +346,27 @@ func handleUpload(c *gin.Context) {
 	duration := time.Since(startTime)
 
 	// 构建下载URL
-	scheme := "http"
-	if c.Request.TLS != nil {
-		scheme = "https"
+	downloadURL := buildDownloadURL(c, result.Filename)
+
+	utils.Info("音频转换成功: %s -> %s (耗时: %.2f秒)", file.Filename, result.Filename, duration.Seconds())
+	resp := gin.H{
+		"success":        true,
+		"url":            downloadURL,
+		"filename":       result.Filename,
+		"name":           result.Name,
+		"audio_duration": result.AudioDuration,
+		"size":           result.Size,
+		"duration":       fmt.Sprintf("%.2f秒", duration.Seconds()),
 	}
-	host := c.Request.Host
-	downloadURL := fmt.Sprintf("%s://%s/download/%s", scheme, host, filename)
-
-	utils.Info("音频转换成功: %s -> %s (耗时: %.2f秒)", file.Filename, filename, duration.Seconds())
-	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"url":      downloadURL,
-		"filename": filename,
-		"duration": fmt.Sprintf("%.2f秒", duration.Seconds()),
-	})
+	if len(result.Filters) > 0 {
+		resp["filters"] = result.Filters
+	}
+	if len(result.Parts) > 0 {
+		resp["parts"] = partsResponse(result.Parts, func(filename string) string {
+			return buildDownloadURL(c, filename)
+		})
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // 处理URL转换请求
@@ -199,9 +375,14 @@ func handleURL(c *gin.Context) {
 
 	var req struct {
 		URL string `json:"url" binding:"required"`
+		services.ConvertOptions
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
+		if isBodyTooLarge(err) {
+			respondTooLarge(c, cfg.Limits.MaxJSONBody)
+			return
+		}
 		utils.Error("无效的URL请求参数: %s: %v", clientIP, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
 		return
@@ -211,10 +392,16 @@ func handleURL(c *gin.Context) {
 
 	// 调用音频转换服务
 	startTime := time.Now()
-	filename, err := audioService.ConvertToSilk(req.URL)
+	opts := req.ConvertOptions
+	opts.OriginalName = services.OriginalNameFromURL(req.URL)
+	result, err := audioService.Convert(c.Request.Context(), services.RemoteURLInput{URL: req.URL}, opts)
+	if errors.Is(err, services.ErrServerBusy) {
+		respondBusy(c, http.StatusTooManyRequests, err)
+		return
+	}
 	if err != nil {
 		utils.Error("URL音频转换失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "音频转换失败: " + err.Error()})
+		c.JSON(convertErrorStatus(err), gin.H{"error": "音频转换失败: " + err.Error()})
 		return
 	}
 
@@ -222,19 +409,113 @@ func handleURL(c *gin.Context) {
 	duration := time.Since(startTime)
 
 	// 构建下载URL
-	scheme := "http"
-	if c.Request.TLS != nil {
-		scheme = "https"
+	downloadURL := buildDownloadURL(c, result.Filename)
+
+	utils.Info("URL音频转换成功: %s (耗时: %.2f秒)", result.Filename, duration.Seconds())
+	resp := gin.H{
+		"success":        true,
+		"url":            downloadURL,
+		"filename":       result.Filename,
+		"name":           result.Name,
+		"audio_duration": result.AudioDuration,
+		"size":           result.Size,
+		"duration":       fmt.Sprintf("%.2f秒", duration.Seconds()),
+	}
+	if len(result.Filters) > 0 {
+		resp["filters"] = result.Filters
+	}
+	if len(result.Parts) > 0 {
+		resp["parts"] = partsResponse(result.Parts, func(filename string) string {
+			return buildDownloadURL(c, filename)
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 处理SILK解码请求
+func handleDecode(c *gin.Context) {
+	clientIP := c.ClientIP()
+	var input services.Input
+	var opts services.DecodeOptions
+	var source string
+
+	// 支持文件上传和URL两种方式
+	if strings.Contains(c.GetHeader("Content-Type"), "multipart/form-data") {
+		file, err := formFile(c)
+		if errors.Is(err, errUploadTooLarge) {
+			respondTooLarge(c, cfg.Limits.MaxUploadSize)
+			return
+		}
+		if err != nil {
+			utils.Error("上传SILK文件失败: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "上传文件失败: " + err.Error()})
+			return
+		}
+
+		input, err = saveUpload(file)
+		if err != nil {
+			c.JSON(convertErrorStatus(err), gin.H{"error": "无法读取上传的文件: " + err.Error()})
+			return
+		}
+		opts.Format = c.PostForm("format")
+		opts.NameTemplate = c.PostForm("name_template")
+		opts.OriginalName = file.Filename
+		source = file.Filename
+	} else {
+		var req struct {
+			inputRequest
+			services.DecodeOptions
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if isBodyTooLarge(err) {
+				respondTooLarge(c, uploadBodyLimit())
+				return
+			}
+			utils.Error("无效的解码请求参数: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+			return
+		}
+		input = req.input()
+		if err := audioService.ValidateInput(input); err != nil {
+			c.JSON(inputErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		opts = req.DecodeOptions
+		opts.OriginalName = req.originalName()
+		source = input.Source()
 	}
-	host := c.Request.Host
-	downloadURL := fmt.Sprintf("%s://%s/download/%s", scheme, host, filename)
 
-	utils.Info("URL音频转换成功: %s (耗时: %.2f秒)", filename, duration.Seconds())
+	utils.Info("收到SILK解码请求: %s, 来源: %s, 格式: %s", clientIP, source, opts.Format)
+
+	// 调用音频解码服务
+	startTime := time.Now()
+	result, err := audioService.ConvertFromSilk(c.Request.Context(), input, opts)
+	if errors.Is(err, services.ErrServerBusy) {
+		respondBusy(c, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil {
+		utils.Error("SILK解码失败: %v", err)
+		c.JSON(convertErrorStatus(err), gin.H{"error": "SILK解码失败: " + err.Error()})
+		return
+	}
+
+	// 计算处理时间
+	duration := time.Since(startTime)
+
+	// 构建下载URL
+	downloadURL := buildDownloadURL(c, result.Filename)
+
+	utils.Info("SILK解码成功: %s -> %s (耗时: %.2f秒)", source, result.Filename, duration.Seconds())
 	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"url":      downloadURL,
-		"filename": filename,
-		"duration": fmt.Sprintf("%.2f秒", duration.Seconds()),
+		"success":        true,
+		"url":            downloadURL,
+		"filename":       result.Filename,
+		"name":           result.Name,
+		"audio_duration": result.AudioDuration,
+		"size":           result.Size,
+		"duration":       fmt.Sprintf("%.2f秒", duration.Seconds()),
 	})
 }
 
@@ -243,52 +524,65 @@ func handleTTS(c *gin.Context) {
 	clientIP := c.ClientIP()
 
 	var req struct {
-		Text string `json:"text" binding:"required"`
+		services.TTSRequest
+		services.ConvertOptions
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
+		if isBodyTooLarge(err) {
+			respondTooLarge(c, cfg.Limits.MaxJSONBody)
+			return
+		}
 		utils.Error("无效的TTS请求参数: %s: %v", clientIP, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
 		return
 	}
 
 	utils.Info("收到TTS请求: %s, 文本长度: %d", clientIP, len(req.Text))
-	utils.Warn("TTS功能尚未实现")
 
-	// 这里可以集成第三方TTS服务
-	// 暂时返回不支持
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "TTS功能尚未实现"})
-}
-
-// 获取本地IP地址
-func getLocalIP() string {
-	addrs, err := net.InterfaceAddrs()
+	startTime := time.Now()
+	opts := req.ConvertOptions
+	opts.OriginalName = "tts"
+	result, err := audioService.TextToSilk(c.Request.Context(), req.TTSRequest, opts)
+	if errors.Is(err, services.ErrServerBusy) {
+		respondBusy(c, http.StatusTooManyRequests, err)
+		return
+	}
 	if err != nil {
-		utils.Error("获取本地IP失败: %v", err)
-		return "localhost"
+		utils.Error("TTS转换失败: %v", err)
+		c.JSON(convertErrorStatus(err), gin.H{"error": "TTS转换失败: " + err.Error()})
+		return
 	}
 
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				return ipnet.IP.String()
-			}
-		}
-	}
-	return "localhost"
+	duration := time.Since(startTime)
+	utils.Info("TTS转换成功: %s (耗时: %.2f秒)", result.Filename, duration.Seconds())
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"url":            buildDownloadURL(c, result.Filename),
+		"filename":       result.Filename,
+		"name":           result.Name,
+		"audio_duration": result.AudioDuration,
+		"size":           result.Size,
+		"duration":       fmt.Sprintf("%.2f秒", duration.Seconds()),
+	})
 }
 
 // 处理音频转换
 func handleConvert(c *gin.Context) {
 	startTime := time.Now()
-	var input interface{}
+	var input services.Input
+	var opts services.ConvertOptions
 	var err error
 
 	// 检查请求的Content-Type
 	contentType := c.GetHeader("Content-Type")
 	if strings.Contains(contentType, "multipart/form-data") {
 		// 处理文件上传
-		file, err := c.FormFile("file")
+		file, err := formFile(c)
+		if errors.Is(err, errUploadTooLarge) {
+			respondTooLarge(c, cfg.Limits.MaxUploadSize)
+			return
+		}
 		if err != nil {
 			utils.Error("获取上传文件失败: %v", err)
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -298,24 +592,31 @@ func handleConvert(c *gin.Context) {
 			return
 		}
 
-		// 保存上传的文件
-		filename := filepath.Base(file.Filename)
-		filepath := filepath.Join(uploadDir, filename)
-		if err := c.SaveUploadedFile(file, filepath); err != nil {
-			utils.Error("保存上传文件失败: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
+		if !bindConvertForm(c, &opts) {
+			return
+		}
+
+		// 保存上传的文件，使用随机文件名避免同名上传互相覆盖
+		input, err = saveUpload(file)
+		if err != nil {
+			c.JSON(convertErrorStatus(err), gin.H{
 				"success": false,
-				"error":   "保存上传文件失败",
+				"error":   "保存上传文件失败: " + err.Error(),
 			})
 			return
 		}
-		input = filepath
+		opts.OriginalName = file.Filename
 	} else if strings.Contains(contentType, "application/json") {
-		// 处理URL
+		// 处理URL或导入目录中的文件
 		var request struct {
-			URL string `json:"url"`
+			inputRequest
+			services.ConvertOptions
 		}
 		if err := c.ShouldBindJSON(&request); err != nil {
+			if isBodyTooLarge(err) {
+				respondTooLarge(c, uploadBodyLimit())
+				return
+			}
 			utils.Error("解析URL请求失败: %v", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
@@ -323,7 +624,17 @@ func handleConvert(c *gin.Context) {
 			})
 			return
 		}
-		input = request.URL
+		input = request.input()
+		if err := audioService.ValidateInput(input); err != nil {
+			utils.Warn("拒绝无效的转换输入: %s: %v", c.ClientIP(), err)
+			c.JSON(inputErrorStatus(err), gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		opts = request.ConvertOptions
+		opts.OriginalName = request.originalName()
 	} else {
 		utils.Error("不支持的Content-Type: %s", contentType)
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -334,10 +645,14 @@ func handleConvert(c *gin.Context) {
 	}
 
 	// 转换音频
-	filename, err := audioService.ConvertToSilk(input)
+	result, err := audioService.Convert(c.Request.Context(), input, opts)
+	if errors.Is(err, services.ErrServerBusy) {
+		respondBusy(c, http.StatusTooManyRequests, err)
+		return
+	}
 	if err != nil {
 		utils.Error("音频转换失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(convertErrorStatus(err), gin.H{
 			"success": false,
 			"error":   "音频转换失败: " + err.Error(),
 		})
@@ -345,19 +660,357 @@ func handleConvert(c *gin.Context) {
 	}
 
 	// 生成下载URL
-	localIP := getLocalIP()
-	downloadURL := fmt.Sprintf("http://%s:%s/download/%s", localIP, *port, filename)
+	downloadURL := buildDownloadURL(c, result.Filename)
 	duration := time.Since(startTime).String()
 
 	utils.Info("音频转换成功: %s", downloadURL)
-	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"url":      downloadURL,
-		"filename": filename,
-		"duration": duration,
+	resp := gin.H{
+		"success":        true,
+		"url":            downloadURL,
+		"filename":       result.Filename,
+		"name":           result.Name,
+		"audio_duration": result.AudioDuration,
+		"size":           result.Size,
+		"cached":         result.Cached,
+		"duration":       duration,
+	}
+	if len(result.Filters) > 0 {
+		resp["filters"] = result.Filters
+	}
+	if len(result.Parts) > 0 {
+		resp["parts"] = partsResponse(result.Parts, func(filename string) string {
+			return buildDownloadURL(c, filename)
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 分段转换时返回各分段的下载信息，downloadURL 根据存储文件名生成下载链接
+func partsResponse(parts []*services.ConvertResult, downloadURL func(filename string) string) []gin.H {
+	list := make([]gin.H, 0, len(parts))
+	for i, part := range parts {
+		list = append(list, gin.H{
+			"index":          i + 1,
+			"url":            downloadURL(part.Filename),
+			"filename":       part.Filename,
+			"name":           part.Name,
+			"audio_duration": part.AudioDuration,
+			"size":           part.Size,
+		})
+	}
+	return list
+}
+
+// 服务繁忙时返回429/503及Retry-After
+func respondBusy(c *gin.Context, status int, err error) {
+	utils.Warn("服务繁忙，拒绝请求: %s: %v", c.ClientIP(), err)
+	c.Header("Retry-After", strconv.Itoa(cfg.Limits.RetryAfter))
+	c.JSON(status, gin.H{
+		"success":     false,
+		"error":       err.Error(),
+		"retry_after": cfg.Limits.RetryAfter,
 	})
 }
 
+// 根据转换错误返回HTTP状态码：阶段超时504，客户端断开499，其余500
+func convertErrorStatus(err error) int {
+	switch {
+	case services.IsTimeout(err):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, services.ErrUnsupportedMedia):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, services.ErrNoAudioStream):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrInvalidInput), errors.Is(err, services.ErrImportDisabled),
+		errors.Is(err, services.ErrInvalidOptions):
+		return http.StatusBadRequest
+	case services.IsFetchRejected(err):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrFetchTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrFetchStatus), errors.Is(err, services.ErrFetchTooManyRedirects),
+		errors.Is(err, services.ErrTTSProvider):
+		return http.StatusBadGateway
+	case errors.Is(err, services.ErrTTSUnavailable), errors.Is(err, services.ErrTranscribeUnavailable):
+		return http.StatusNotImplemented
+	case errors.Is(err, context.Canceled):
+		return 499
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// 输入校验失败的状态码：内容不是音频返回415，其余400
+func inputErrorStatus(err error) int {
+	if errors.Is(err, services.ErrUnsupportedMedia) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+// JSON请求中的转换输入：远程URL或导入目录中的文件，二选一
+type inputRequest struct {
+	URL        string `json:"url"`
+	ServerFile string `json:"server_file"` // 相对 -import-dir 的路径
+}
+
+// 根据请求字段构建输入，不会把任意字符串当作本地路径
+func (r inputRequest) input() services.Input {
+	if r.ServerFile != "" {
+		if r.URL != "" {
+			return nil
+		}
+		return services.ServerFileInput{Name: r.ServerFile}
+	}
+	if r.URL == "" {
+		return nil
+	}
+	return services.RemoteURLInput{URL: r.URL}
+}
+
+// 输入对应的原始文件名
+func (r inputRequest) originalName() string {
+	if r.ServerFile != "" {
+		return path.Base(filepath.ToSlash(r.ServerFile))
+	}
+	return services.OriginalNameFromURL(r.URL)
+}
+
+// multipart请求中表单字段和分隔符的额外开销上限
+const multipartOverhead = 64 << 10
+
+// errUploadTooLarge 上传文件或请求体超过大小限制
+var errUploadTooLarge = errors.New("上传文件过大")
+
+// 限制请求体大小，Content-Length已超出时直接返回413
+func limitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			utils.Warn("请求体过大: %s, 大小: %d, 上限: %d", c.ClientIP(), c.Request.ContentLength, limit)
+			respondTooLarge(c, limit)
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// 上传接口的请求体上限：文件上限加上multipart开销
+func uploadBodyLimit() int64 {
+	return cfg.Limits.MaxUploadSize + multipartOverhead
+}
+
+// 判断错误是否由请求体超出 http.MaxBytesReader 限制引起
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// 返回413错误
+func respondTooLarge(c *gin.Context, limit int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"success": false,
+		"error":   fmt.Sprintf("请求内容过大，上限 %d 字节", limit),
+		"limit":   limit,
+	})
+}
+
+// 获取上传的文件，超过 MaxMultipartMemory 的部分由标准库写入临时文件而不是留在内存中
+func formFile(c *gin.Context) (*multipart.FileHeader, error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		if isBodyTooLarge(err) {
+			return nil, errUploadTooLarge
+		}
+		return nil, err
+	}
+	if file.Size > cfg.Limits.MaxUploadSize {
+		utils.Warn("上传文件过大: %s, 大小: %d", file.Filename, file.Size)
+		return nil, errUploadTooLarge
+	}
+	return file, nil
+}
+
+// 从表单中读取转换参数（编解码器、命名、SILK编码和分段参数），
+// 需在保存上传文件之前调用，参数错误时不会遗留文件
+func bindConvertForm(c *gin.Context, opts *services.ConvertOptions) bool {
+	if err := c.ShouldBind(opts); err != nil {
+		utils.Error("无效的转换参数: %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的转换参数: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// 将上传的文件保存到上传目录
+func saveUpload(file *multipart.FileHeader) (services.UploadInput, error) {
+	src, err := file.Open()
+	if err != nil {
+		utils.Error("无法读取上传的文件: %v", err)
+		return services.UploadInput{}, err
+	}
+	defer src.Close()
+	input, err := audioService.SaveUpload(src)
+	if errors.Is(err, services.ErrUnsupportedMedia) {
+		utils.Warn("拒绝非音频上传: %s: %v", file.Filename, err)
+	}
+	return input, err
+}
+
+// 构建下载URL
+func buildDownloadURL(c *gin.Context, filename string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/download/%s", scheme, c.Request.Host, filename)
+}
+
+// 处理创建异步任务请求
+func handleCreateJob(c *gin.Context) {
+	clientIP := c.ClientIP()
+	var input services.Input
+	var opts services.ConvertOptions
+
+	if strings.Contains(c.GetHeader("Content-Type"), "multipart/form-data") {
+		file, err := formFile(c)
+		if errors.Is(err, errUploadTooLarge) {
+			respondTooLarge(c, cfg.Limits.MaxUploadSize)
+			return
+		}
+		if err != nil {
+			utils.Error("获取上传文件失败: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "获取上传文件失败"})
+			return
+		}
+
+		if !bindConvertForm(c, &opts) {
+			return
+		}
+
+		// 上传文件需在请求结束前落盘，使用唯一文件名避免冲突
+		input, err = saveUpload(file)
+		if err != nil {
+			c.JSON(convertErrorStatus(err), gin.H{"success": false, "error": "保存上传文件失败: " + err.Error()})
+			return
+		}
+		opts.OriginalName = file.Filename
+	} else {
+		var req struct {
+			inputRequest
+			services.ConvertOptions
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if isBodyTooLarge(err) {
+				respondTooLarge(c, uploadBodyLimit())
+				return
+			}
+			utils.Error("无效的任务请求参数: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求参数: " + err.Error()})
+			return
+		}
+		input = req.input()
+		if err := audioService.ValidateInput(input); err != nil {
+			c.JSON(inputErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		opts = req.ConvertOptions
+		opts.OriginalName = req.originalName()
+	}
+
+	job, err := jobManager.Submit(input, opts)
+	if err != nil {
+		audioService.DiscardInput(input)
+		utils.Warn("提交任务失败: %s: %v", clientIP, err)
+		if errors.Is(err, services.ErrJobQueueFull) {
+			respondBusy(c, http.StatusServiceUnavailable, err)
+		} else {
+			c.JSON(inputErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	utils.Info("已创建任务: %s, 客户端: %s", job.ID, clientIP)
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"job_id":  job.ID,
+		"status":  job.Status,
+	})
+}
+
+// 构建任务状态响应
+func jobResponse(c *gin.Context, job services.Job) gin.H {
+	resp := gin.H{
+		"success": true,
+		"job":     job,
+	}
+	if job.Status == services.JobSucceeded {
+		resp["url"] = buildDownloadURL(c, job.Filename)
+		if len(job.Parts) > 0 {
+			resp["parts"] = partsResponse(job.Parts, func(filename string) string {
+				return buildDownloadURL(c, filename)
+			})
+		}
+	}
+	return resp
+}
+
+// 处理查询任务状态请求
+func handleGetJob(c *gin.Context) {
+	job, err := jobManager.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, jobResponse(c, job))
+}
+
+// 通过SSE推送任务进度，任务结束后发送done事件并关闭连接
+func handleJobEvents(c *gin.Context) {
+	updates, unsubscribe, err := jobManager.Subscribe(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case job := <-updates:
+			if job.Finished() {
+				c.SSEvent("done", jobResponse(c, job))
+				return false
+			}
+			c.SSEvent("progress", jobResponse(c, job))
+			return true
+		case <-keepalive.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// 处理取消任务请求
+func handleCancelJob(c *gin.Context) {
+	job, err := jobManager.Cancel(c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, jobResponse(c, job))
+	case services.ErrJobNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error(), "job": job})
+	}
+}
+
 // 处理下载请求
 func handleDownload(c *gin.Context) {
 	clientIP := c.ClientIP()
@@ -378,8 +1031,15 @@ func handleDownload(c *gin.Context) {
 		return
 	}
 
+	// 未完成的临时输出不允许下载
+	if services.IsTempOutput(filename) {
+		utils.Warn("请求未完成的输出文件: %s, 文件: %s", clientIP, filename)
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+
 	// 构建文件路径
-	filePath := filepath.Join(silkDir, filename)
+	filePath := filepath.Join(audioService.SilkDir, filename)
 
 	// 检查文件是否存在
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -388,9 +1048,19 @@ func handleDownload(c *gin.Context) {
 		return
 	}
 
+	// 下载文件名优先使用转换时记录的展示名称（模板生成或原始文件名）
+	name := filename
+	if meta, err := audioService.ReadMeta(filename); err == nil && meta.Name != "" {
+		name = meta.Name
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name})
+	if disposition == "" {
+		disposition = mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	}
+
 	// 设置文件名和内容类型
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", disposition)
+	c.Header("Content-Type", services.ContentType(filename))
 
 	utils.Info("提供文件下载: %s -> %s", filename, clientIP)
 
@@ -398,10 +1068,176 @@ func handleDownload(c *gin.Context) {
 	c.File(filePath)
 }
 
+// 读取音频元数据：时长、采样率、声道、编码和比特率，支持文件上传或JSON中的URL/导入文件
+func handleProbe(c *gin.Context) {
+	clientIP := c.ClientIP()
+	var input services.Input
+
+	if strings.Contains(c.GetHeader("Content-Type"), "multipart/form-data") {
+		file, err := formFile(c)
+		if errors.Is(err, errUploadTooLarge) {
+			respondTooLarge(c, cfg.Limits.MaxUploadSize)
+			return
+		}
+		if err != nil {
+			utils.Error("上传文件失败: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "上传文件失败: " + err.Error()})
+			return
+		}
+		input, err = saveUpload(file)
+		if err != nil {
+			c.JSON(convertErrorStatus(err), gin.H{"success": false, "error": "保存上传文件失败: " + err.Error()})
+			return
+		}
+	} else {
+		var req inputRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if isBodyTooLarge(err) {
+				respondTooLarge(c, uploadBodyLimit())
+				return
+			}
+			utils.Error("无效的探测请求参数: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求参数: " + err.Error()})
+			return
+		}
+		input = req.input()
+		if err := audioService.ValidateInput(input); err != nil {
+			c.JSON(inputErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	utils.Info("收到音频探测请求: %s, 来源: %s", clientIP, input.Source())
+	info, err := audioService.Probe(c.Request.Context(), input)
+	if err != nil {
+		utils.Error("读取音频信息失败: %v", err)
+		c.JSON(convertErrorStatus(err), gin.H{"success": false, "error": "读取音频信息失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "info": info})
+}
+
+// 语音识别：支持文件上传或JSON中的URL/导入文件，SILK与其他格式统一解码为16kHz PCM后识别
+// format为srt或vtt时直接返回字幕文件
+func handleTranscribe(c *gin.Context) {
+	clientIP := c.ClientIP()
+	var input services.Input
+	var opts services.TranscribeOptions
+
+	if strings.Contains(c.GetHeader("Content-Type"), "multipart/form-data") {
+		file, err := formFile(c)
+		if errors.Is(err, errUploadTooLarge) {
+			respondTooLarge(c, cfg.Limits.MaxUploadSize)
+			return
+		}
+		if err != nil {
+			utils.Error("上传文件失败: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "上传文件失败: " + err.Error()})
+			return
+		}
+		if err := c.ShouldBind(&opts); err != nil {
+			utils.Error("无效的识别参数: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的识别参数: " + err.Error()})
+			return
+		}
+		input, err = saveUpload(file)
+		if err != nil {
+			c.JSON(convertErrorStatus(err), gin.H{"success": false, "error": "保存上传文件失败: " + err.Error()})
+			return
+		}
+	} else {
+		var req struct {
+			inputRequest
+			services.TranscribeOptions
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if isBodyTooLarge(err) {
+				respondTooLarge(c, uploadBodyLimit())
+				return
+			}
+			utils.Error("无效的识别请求参数: %s: %v", clientIP, err)
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求参数: " + err.Error()})
+			return
+		}
+		input = req.input()
+		opts = req.TranscribeOptions
+		if err := audioService.ValidateInput(input); err != nil {
+			c.JSON(inputErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	utils.Info("收到语音识别请求: %s, 来源: %s", clientIP, input.Source())
+	startTime := time.Now()
+	transcript, err := audioService.Transcribe(c.Request.Context(), input, opts)
+	if errors.Is(err, services.ErrServerBusy) {
+		respondBusy(c, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil {
+		utils.Error("语音识别失败: %v", err)
+		c.JSON(convertErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	duration := time.Since(startTime)
+	utils.Info("语音识别成功: %s, %d字 (耗时: %.2f秒)", input.Source(), len([]rune(transcript.Text)), duration.Seconds())
+	switch strings.ToLower(opts.Format) {
+	case services.TranscriptSRT:
+		c.Data(http.StatusOK, "application/x-subrip; charset=utf-8", []byte(transcript.SRT()))
+	case services.TranscriptVTT:
+		c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(transcript.VTT()))
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success":        true,
+			"text":           transcript.Text,
+			"language":       transcript.Language,
+			"segments":       transcript.Segments,
+			"audio_duration": transcript.Duration,
+			"duration":       fmt.Sprintf("%.2f秒", duration.Seconds()),
+		})
+	}
+}
+
+// 获取TTS引擎的发音人列表，provider为空时返回所有引擎
+func handleGetTTSVoices(c *gin.Context) {
+	voices, err := audioService.TTSVoices(c.Request.Context(), c.Query("provider"))
+	if err != nil {
+		utils.Error("读取发音人列表失败: %v", err)
+		c.JSON(convertErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "voices": voices})
+}
+
+// 获取已注册的解码器和编码器
+func handleGetBackends(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"decoders": audioService.Registry.DecoderNames(),
+		"encoders": audioService.Registry.EncoderNames(),
+		"formats":  services.OutputFormatNames(),
+		"tts":      audioService.Registry.TTSNames(),
+		"silk_options": gin.H{
+			"sample_rates": silk.SupportedSampleRates,
+			"bitrate":      []int{services.MinSilkBitRate, services.MaxSilkBitRate},
+			"complexity":   []int{0, services.MaxSilkComplexity},
+			"packet_sizes": services.SupportedSilkPacketSizes,
+		},
+		"filter_options": gin.H{
+			"target_lufs": []float64{services.MinTargetLUFS, services.MaxTargetLUFS},
+			"gain":        []float64{-services.MaxGainDB, services.MaxGainDB},
+			"frequency":   []int{services.MinFilterFreq, services.MaxFilterFreq},
+			"speed":       []float64{services.MinSpeed, services.MaxSpeed},
+			"pitch":       []float64{-services.MaxPitch, services.MaxPitch},
+		},
+	})
+}
+
 // 获取文件列表
 func handleGetFiles(c *gin.Context) {
 	// 获取上传目录的文件列表
-	uploadFiles, err := getFileList(uploadDir)
+	uploadFiles, err := getFileList(audioService.UploadDir)
 	if err != nil {
 		utils.Error("获取上传文件列表失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -412,7 +1248,7 @@ func handleGetFiles(c *gin.Context) {
 	}
 
 	// 获取SILK目录的文件列表
-	silkFiles, err := getFileList(silkDir)
+	silkFiles, err := getFileList(audioService.SilkDir)
 	if err != nil {
 		utils.Error("获取SILK文件列表失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -429,7 +1265,7 @@ func handleGetFiles(c *gin.Context) {
 	})
 }
 
-// 获取目录下的文件列表
+// 获取目录下的文件列表，只返回存储文件名，不暴露下载文件名和原始文件名
 func getFileList(dir string) ([]map[string]interface{}, error) {
 	files, err := os.ReadDir(dir)
 	if err != nil {
@@ -438,15 +1274,16 @@ func getFileList(dir string) ([]map[string]interface{}, error) {
 
 	var fileList []map[string]interface{}
 	for _, file := range files {
-		if !file.IsDir() {
+		if !file.IsDir() && !services.IsTempOutput(file.Name()) {
 			info, err := file.Info()
 			if err != nil {
 				continue
 			}
-			fileList = append(fileList, map[string]interface{}{
+			item := map[string]interface{}{
 				"name": file.Name(),
 				"time": info.ModTime().Unix() * 1000, // 转换为毫秒时间戳
-			})
+			}
+			fileList = append(fileList, item)
 		}
 	}
 	return fileList, nil
@@ -455,30 +1292,43 @@ func getFileList(dir string) ([]map[string]interface{}, error) {
 // 设置路由
 func setupRouter() *gin.Engine {
 	// 根据调试模式设置gin模式
-	if !*debug {
+	if !cfg.Server.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	r := gin.Default()
+	r.MaxMultipartMemory = cfg.Limits.MaxMultipartMemory
+	upload := limitBody(uploadBodyLimit())
+	jsonBody := limitBody(cfg.Limits.MaxJSONBody)
 
 	// 设置静态文件路由
 	r.Static("/static", "./static")
 
 	// 配置API路由
 	r.GET("/", handleIndex)
-	r.POST("/upload", handleUpload)
-	r.POST("/url", handleURL)
-	r.POST("/tts", handleTTS)
-	r.POST("/convert", handleConvert)
+	r.POST("/upload", upload, handleUpload)
+	r.POST("/url", jsonBody, handleURL)
+	r.POST("/tts", jsonBody, handleTTS)
+	r.POST("/convert", upload, handleConvert)
+	r.POST("/decode", upload, handleDecode)
 	r.GET("/download/:filename", handleDownload)
 	r.GET("/api/files", handleGetFiles)
+	r.GET("/api/backends", handleGetBackends)
+	r.GET("/api/tts/voices", handleGetTTSVoices)
+	r.POST("/api/probe", upload, handleProbe)
+	r.POST("/api/transcribe", upload, handleTranscribe)
+	r.POST("/api/jobs", upload, handleCreateJob)
+	r.GET("/api/jobs/:id", handleGetJob)
+	r.GET("/api/jobs/:id/events", handleJobEvents)
+	r.DELETE("/api/jobs/:id", handleCancelJob)
 
 	return r
 }
 
 func main() {
-	// 解析命令行参数
+	// 解析命令行参数并加载配置
 	flag.Parse()
+	loadConfig()
 
 	// 初始化服务
 	initService()
@@ -489,26 +1339,35 @@ func main() {
 
 	// 设置优雅关闭
 	srv := &http.Server{
-		Addr:    ":" + *port,
+		Addr:    ":" + cfg.Server.Port,
 		Handler: r,
 	}
 
 	// 启动服务器
 	go func() {
-		utils.Info("音频转换服务启动在 http://localhost:%s", *port)
-		utils.Info("上传目录: %s", uploadDir)
-		utils.Info("输出目录: %s", silkDir)
+		utils.Info("音频转换服务启动在 http://localhost:%s", cfg.Server.Port)
+		utils.Info("上传目录: %s", audioService.UploadDir)
+		utils.Info("输出目录: %s", audioService.SilkDir)
 
 		// 在调试模式下显示路由信息
-		if *debug {
+		if cfg.Server.Debug {
 			utils.Debug("路由配置:")
 			utils.Debug("  GET  /                - 首页")
 			utils.Debug("  POST /upload          - 文件上传接口")
 			utils.Debug("  POST /url             - URL转换接口")
-			utils.Debug("  POST /tts             - 文本转语音接口(未实现)")
+			utils.Debug("  POST /tts             - 文本转语音接口")
 			utils.Debug("  POST /convert         - 音频转换接口")
+			utils.Debug("  POST /decode          - SILK解码接口")
 			utils.Debug("  GET  /download/:file  - 文件下载接口")
 			utils.Debug("  GET  /api/files       - 文件列表接口")
+			utils.Debug("  GET  /api/backends    - 编解码后端列表")
+			utils.Debug("  GET  /api/tts/voices  - TTS发音人列表")
+			utils.Debug("  POST /api/probe       - 读取音频信息")
+			utils.Debug("  POST /api/transcribe  - 语音识别接口")
+			utils.Debug("  POST /api/jobs        - 创建异步转换任务")
+			utils.Debug("  GET  /api/jobs/:id    - 查询任务状态")
+			utils.Debug("  GET  /api/jobs/:id/events - 任务进度(SSE)")
+			utils.Debug("  DELETE /api/jobs/:id  - 取消任务")
 			utils.Debug("  GET  /static/*file    - 静态资源")
 		}
 
@@ -524,8 +1383,12 @@ func main() {
 
 	utils.Info("正在关闭服务器...")
 
-	// 关闭前执行清理任务
-	cleanTempFiles()
+	// 终止未完成的异步任务，等待工作协程退出后再清理临时文件，避免删除仍在写入的文件
+	if jobManager.Shutdown(shutdownTimeout) {
+		cleanTempFiles()
+	} else {
+		utils.Warn("仍有任务未退出，跳过临时文件清理")
+	}
 
 	utils.Info("服务器已关闭")
 }
@@ -1,44 +1,116 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"audio-converter/services/silk"
 	"audio-converter/utils"
 )
 
 type AudioService struct {
-	UploadDir   string
-	SilkDir     string
-	FfmpegPath  string
-	EncoderPath string
+	UploadDir     string
+	SilkDir       string
+	ImportDir     string // 允许作为输入的服务器本地文件目录，为空时禁用
+	FfmpegPath    string
+	FfprobePath   string
+	EncoderPath   string
+	DecoderPath   string
+	Registry      *Registry   // 解码器与编码器注册表
+	Limiter       *Limiter    // 并发转换限制，为nil时不限制
+	Cache         *Cache      // 转换结果缓存，为nil时不缓存
+	Fetcher       *Fetcher    // URL下载器，负责SSRF防护和大小限制
+	SampleRate    int         // 编码器输入PCM采样率，0表示默认24kHz
+	DefaultTTS    string      // 默认TTS引擎，为空时使用第一个已注册的引擎
+	MaxTTSChars   int         // 单次请求的文本长度上限，0表示 DefaultMaxTTSChars
+	TTSChunkChars int         // 单次提交给TTS引擎的文本长度，0表示 DefaultTTSChunkChars
+	Transcriber   Transcriber // 语音识别引擎，为nil时不可用
+
+	// 各阶段超时，0表示不限制
+	DownloadTimeout   time.Duration
+	DecodeTimeout     time.Duration
+	EncodeTimeout     time.Duration
+	ProbeTimeout      time.Duration // 单次ffprobe调用的超时
+	TTSTimeout        time.Duration
+	TranscribeTimeout time.Duration
+}
+
+// 各阶段超时错误
+var (
+	ErrDownloadTimeout = errors.New("下载超时")
+	ErrDecodeTimeout   = errors.New("解码超时")
+	ErrEncodeTimeout   = errors.New("编码超时")
+)
+
+// IsTimeout 判断错误是否为阶段超时
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrDownloadTimeout) || errors.Is(err, ErrDecodeTimeout) || errors.Is(err, ErrEncodeTimeout) ||
+		errors.Is(err, ErrFetchTimeout) || errors.Is(err, ErrProbeTimeout) || errors.Is(err, ErrTTSTimeout) ||
+		errors.Is(err, ErrTranscribeTimeout)
+}
+
+// stageContext 为转换阶段创建带超时的上下文
+func stageContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// stageError 区分阶段超时与调用方取消：阶段超时返回timeoutErr，调用方取消返回其ctx错误
+func stageError(parent, stage context.Context, err, timeoutErr error) error {
+	if err == nil {
+		return nil
+	}
+	if parent.Err() != nil {
+		return parent.Err()
+	}
+	if errors.Is(stage.Err(), context.DeadlineExceeded) {
+		return timeoutErr
+	}
+	return err
+}
+
+// Tools 外部工具路径，为空的项在PATH和默认位置中查找
+type Tools struct {
+	FFmpeg  string
+	FFprobe string
+	Encoder string
+	Decoder string
+	Sox     string
 }
 
 // NewAudioService 创建新的音频服务
-func NewAudioService(uploadDir, silkDir string) *AudioService {
+func NewAudioService(uploadDir, silkDir string, tools Tools) *AudioService {
 	// 确保目录使用绝对路径
 	absUploadDir, _ := filepath.Abs(uploadDir)
 	absSilkDir, _ := filepath.Abs(silkDir)
 
-	// 设置ffmpeg和encoder路径
-	var ffmpegPath, encoderPath string
+	// 设置ffmpeg、encoder和decoder路径
+	var ffmpegPath, encoderPath, decoderPath string
 
 	// 检测操作系统类型并设置相应的路径
 	if runtime.GOOS == "windows" {
 		// Windows环境
 		ffmpegPath = "D:\\ffmpeg-7.1.1-essentials_build\\bin\\ffmpeg.exe"
 		encoderPath = "D:\\silk\\encoder.exe"
+		decoderPath = "D:\\silk\\decoder.exe"
 	} else {
 		// Linux/Unix环境
 		ffmpegPath = "/usr/bin/ffmpeg"
 		encoderPath = "/usr/local/bin/encoder"
+		decoderPath = "/usr/local/bin/decoder"
 	}
 
 	// 尝试在PATH中查找ffmpeg和encoder
@@ -52,15 +124,65 @@ func NewAudioService(uploadDir, silkDir string) *AudioService {
 		utils.Info("在PATH中找到encoder: %s", encoderPath)
 	}
 
+	if decPath, err := exec.LookPath("decoder"); err == nil {
+		decoderPath = decPath
+		utils.Info("在PATH中找到decoder: %s", decoderPath)
+	}
+
+	// 配置中指定的路径优先
+	if tools.FFmpeg != "" {
+		ffmpegPath = tools.FFmpeg
+	}
+	if tools.Encoder != "" {
+		encoderPath = tools.Encoder
+	}
+
+	// ffprobe通常与ffmpeg一起发布，优先使用同一目录下的版本
+	ffprobePath := tools.FFprobe
+	if ffprobePath == "" {
+		ffprobePath = findFFprobe(ffmpegPath)
+	}
+	if tools.Decoder != "" {
+		decoderPath = tools.Decoder
+	}
+
 	utils.Info("音频服务初始化: 上传目录=%s, SILK目录=%s", absUploadDir, absSilkDir)
 	utils.Debug("FFmpeg路径: %s", ffmpegPath)
+	utils.Debug("FFprobe路径: %s", ffprobePath)
 	utils.Debug("Encoder路径: %s", encoderPath)
+	utils.Debug("Decoder路径: %s", decoderPath)
+
+	// 注册内置解码器和编码器
+	soxPath := tools.Sox
+	if soxPath == "" {
+		soxPath = "sox"
+		if p, err := exec.LookPath("sox"); err == nil {
+			soxPath = p
+		}
+	}
+	registry := NewRegistry()
+	registry.RegisterDecoder(&FFmpegDecoder{Path: ffmpegPath})
+	registry.RegisterDecoder(&SoxDecoder{Path: soxPath})
+	registry.RegisterDecoder(&WAVDecoder{})
+	registry.RegisterEncoder(&SilkEncoder{Path: encoderPath, Tencent: true})
+	for _, f := range outputFormats {
+		registry.RegisterEncoder(&FFmpegEncoder{Path: ffmpegPath, Format: f})
+	}
+	utils.Debug("已注册解码器: %v", registry.DecoderNames())
+	utils.Debug("已注册编码器: %v", registry.EncoderNames())
+
+	// 默认策略不含主机列表，不会解析失败
+	fetcher, _ := NewFetcher(DefaultFetchPolicy())
 
 	return &AudioService{
 		UploadDir:   absUploadDir,
 		SilkDir:     absSilkDir,
 		FfmpegPath:  ffmpegPath,
+		FfprobePath: ffprobePath,
 		EncoderPath: encoderPath,
+		DecoderPath: decoderPath,
+		Registry:    registry,
+		Fetcher:     fetcher,
 	}
 }
 
@@ -74,201 +196,537 @@ func getFileName(filePath string) string {
 	return filepath.Base(filePath)
 }
 
+// resolveInput 将输入解析为本地文件路径，返回的清理函数负责删除下载或上传的临时文件
+func (s *AudioService) resolveInput(ctx context.Context, in Input) (string, func(), error) {
+	if in == nil {
+		return "", nil, fmt.Errorf("%w: 缺少输入", ErrInvalidInput)
+	}
+	path, owned, err := in.resolve(ctx, s)
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {}
+	if owned {
+		cleanup = func() { os.Remove(path) }
+	}
+	return path, cleanup, nil
+}
+
+// ConvertOptions 转换流水线选项，为空时使用默认后端
+type ConvertOptions struct {
+	Decoder string `json:"decoder" form:"decoder"` // 解码器名称: ffmpeg/sox/wav
+	Encoder string `json:"encoder" form:"encoder"` // SILK编码器名称，目前只有encoder
+	Format  string `json:"format" form:"format"`   // 输出格式: silk/amr/amr-wb/opus/ogg/mp3/wav，默认silk
+	NamingOptions
+	SilkOptions
+	SplitOptions
+	FilterOptions
+
+	Progress ProgressFunc `json:"-" form:"-"` // 进度回调，解码器支持时按百分比报告
+}
+
+// DecodeOptions SILK解码选项
+type DecodeOptions struct {
+	Format string `json:"format" form:"format"` // 输出格式，与转换的 format 参数相同（silk除外），默认mp3
+	NamingOptions
+}
+
 // ConvertToSilk 将音频转换为SILK格式
-func (s *AudioService) ConvertToSilk(input interface{}) (string, error) {
-	var inputPath string
-	var err error
-
-	switch v := input.(type) {
-	case string:
-		// 如果是URL
-		if strings.HasPrefix(v, "http") {
-			inputPath, err = s.downloadFromURL(v)
-			if err != nil {
-				utils.Error("下载URL失败: %v", err)
-				return "", err
-			}
-			utils.Info("已下载文件: %s", inputPath)
-		} else {
-			// 如果是本地文件路径
-			inputPath = v
-			utils.Debug("使用本地文件: %s", inputPath)
-		}
-	case []byte:
-		// 如果是文件内容
-		inputPath, err = s.saveUploadedFile(v)
-		if err != nil {
-			utils.Error("保存上传文件失败: %v", err)
-			return "", err
-		}
-		utils.Debug("已保存上传文件: %s", inputPath)
-	default:
-		return "", fmt.Errorf("不支持的输入类型")
+func (s *AudioService) ConvertToSilk(ctx context.Context, input Input) (*ConvertResult, error) {
+	return s.Convert(ctx, input, ConvertOptions{})
+}
+
+// Convert 按选项组合解码器和编码器完成转换
+// 并发转换数已满且等待队列已满时返回 ErrServerBusy，ctx取消时终止所有外部进程
+// UploadInput 类型的输入在返回时总会被删除，内容不是可识别的音频格式时返回 ErrUnsupportedMedia，
+// 参数不合法或编码器不支持时返回 ErrInvalidOptions
+func (s *AudioService) Convert(ctx context.Context, input Input, opts ConvertOptions) (*ConvertResult, error) {
+	if err := s.ValidateConvertOptions(opts); err != nil {
+		s.DiscardInput(input)
+		return nil, err
+	}
+	if err := s.CheckInput(input); err != nil {
+		s.DiscardInput(input)
+		return nil, err
+	}
+	if err := s.acquire(ctx); err != nil {
+		s.DiscardInput(input)
+		return nil, err
 	}
+	defer s.release()
+	return s.convert(ctx, input, opts)
+}
 
-	// 生成输出文件名 (使用年月日时分秒格式)
-	now := time.Now()
-	outputFilename := fmt.Sprintf("%d%02d%02d_%02d%02d%02d.silk",
-		now.Year(), now.Month(), now.Day(),
-		now.Hour(), now.Minute(), now.Second())
-	outputPath := filepath.Join(s.SilkDir, outputFilename)
-	utils.Debug("输出文件路径: %s", outputPath)
+// acquire 获取转换槽位
+func (s *AudioService) acquire(ctx context.Context) error {
+	if s.Limiter == nil {
+		return nil
+	}
+	return s.Limiter.Acquire(ctx)
+}
+
+// release 释放转换槽位
+func (s *AudioService) release() {
+	if s.Limiter != nil {
+		s.Limiter.Release()
+	}
+}
 
-	// 创建临时PCM文件
-	pcmFilename := fmt.Sprintf("%d.pcm", time.Now().UnixNano())
-	pcmPath := filepath.Join(s.UploadDir, pcmFilename)
-	utils.Debug("创建临时PCM文件: %s", pcmPath)
-
-	// 第一步: 使用ffmpeg转换音频为PCM格式
-	cmd := exec.Command(s.FfmpegPath, "-i", inputPath,
-		"-f", "s16le", // 强制16位小端PCM格式
-		"-acodec", "pcm_s16le", // PCM 16位有符号整数小端格式
-		"-ar", "24000", // 采样率24kHz
-		"-ac", "1", // 单声道
-		pcmPath) // 输出到PCM文件
-
-	// 捕获命令输出以便记录
-	stdout, err := cmd.StdoutPipe()
+// convert 执行转换流水线，调用方负责并发控制
+func (s *AudioService) convert(ctx context.Context, input Input, opts ConvertOptions) (*ConvertResult, error) {
+	// 上传文件归转换流程所有，无论成功与否都在结束时删除
+	defer s.DiscardInput(input)
+
+	decoderName := opts.Decoder
+	if decoderName == "" {
+		decoderName = "ffmpeg"
+	}
+	encoderName, err := s.encoderName(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := s.Registry.Decoder(decoderName)
 	if err != nil {
-		return "", fmt.Errorf("创建stdout管道失败: %v", err)
+		return nil, err
 	}
-	stderr, err := cmd.StderrPipe()
+	encoder, err := s.Registry.Encoder(encoderName)
 	if err != nil {
-		return "", fmt.Errorf("创建stderr管道失败: %v", err)
+		return nil, err
+	}
+	if err := checkEncoderOptions(encoder, opts.SilkOptions); err != nil {
+		return nil, err
+	}
+	if err := checkDecoderFilters(decoder, opts.FilterOptions); err != nil {
+		return nil, err
 	}
 
-	utils.Debug("执行FFmpeg命令: %s", cmd.String())
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("启动ffmpeg失败: %v", err)
+	inputPath, cleanup, err := s.resolveInput(ctx, input)
+	if err != nil {
+		return nil, err
 	}
+	defer cleanup()
 
-	// 记录输出
-	go s.logOutput(stdout, false)
-	go s.logOutput(stderr, true)
+	// 在启动ffmpeg之前拒绝无法识别的内容
+	inputFormat, err := SniffFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	utils.Debug("输入格式: %s", inputFormat.Name)
+	if err := s.selectAudioStream(ctx, inputPath, inputFormat, decoder, &opts.FilterOptions); err != nil {
+		return nil, err
+	}
+	if err := s.checkEditRange(ctx, inputPath, opts.EditOptions); err != nil {
+		return nil, err
+	}
 
-	if err := cmd.Wait(); err != nil {
-		os.Remove(pcmPath)
-		return "", fmt.Errorf("PCM转换失败: %v", err)
+	format := s.pcmFormat(opts.SilkOptions)
+
+	// 相同内容和参数的转换直接返回已有结果，分段转换有多个输出，不使用缓存
+	split := opts.SplitSeconds > 0
+	var cacheKey string
+	if !split {
+		params := append([]string{"convert", decoder.Name(), encoder.Name(), strconv.Itoa(format.SampleRate)},
+			opts.SilkOptions.cacheParams()...)
+		params = append(params, opts.FilterOptions.cacheParams()...)
+		var cached string
+		cacheKey, cached = s.lookupCache(inputPath, params...)
+		if cached != "" {
+			if opts.Progress != nil {
+				opts.Progress(100)
+			}
+			result, err := s.reuseCached(ctx, cached, opts.NamingOptions)
+			if err != nil {
+				return nil, err
+			}
+			result.Filters = opts.FilterOptions.Filters()
+			return result, nil
+		}
 	}
-	utils.Info("FFmpeg转换为PCM完成")
 
-	// 第二步: 使用encoder将PCM转换为SILK格式
-	// 根据不同平台使用不同的命令参数
-	var encoderCmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		encoderCmd = exec.Command(s.EncoderPath, pcmPath, outputPath, "-tencent")
+	// 存储文件名使用随机ID，避免并发转换互相覆盖，也无法被猜测
+	outputFilename, name := newOutput(encoder.Ext(), opts.NamingOptions)
+	outputPath := filepath.Join(s.SilkDir, outputFilename)
+	tmpPath := tempOutputPath(outputPath)
+	defer os.Remove(tmpPath)
+
+	// 解码器通过管道将PCM直接交给编码器，不落地临时PCM文件
+	decodeCtx, cancelDecode := stageContext(ctx, s.DecodeTimeout)
+	defer cancelDecode()
+	encodeCtx, cancelEncode := stageContext(ctx, s.EncodeTimeout)
+	defer cancelEncode()
+
+	pr, pw := io.Pipe()
+	decodeErr := make(chan error, 1)
+	go func() {
+		err := decodeInput(decodeCtx, decoder, inputPath, format, pw, opts)
+		pw.CloseWithError(err)
+		decodeErr <- err
+	}()
+
+	var result *ConvertResult
+	var encodeErr error
+	if filters := opts.FilterOptions.Filters(); len(filters) > 0 {
+		utils.Debug("音频处理: %s", strings.Join(filters, ","))
+	}
+	if split {
+		utils.Debug("分段转换: 每段最长%d秒, 静音切分: %v", opts.SplitSeconds, opts.SplitOnSilence)
+		result, encodeErr = s.encodeSplit(encodeCtx, pr, encoder, format, opts)
 	} else {
-		// Linux下的encoder可能有不同的参数格式
-		encoderCmd = exec.Command(s.EncoderPath, pcmPath, outputPath, "-tencent")
+		utils.Debug("输出文件路径: %s", outputPath)
+		encodeErr = s.encodePCM(encodeCtx, encoder, pr, tmpPath, format, opts.SilkOptions)
+	}
+	// 编码器提前退出时关闭读端，避免解码器阻塞在写管道上
+	pr.CloseWithError(fmt.Errorf("编码器已退出"))
+	if err := stageError(ctx, decodeCtx, <-decodeErr, ErrDecodeTimeout); err != nil {
+		if result != nil {
+			s.removeSplit(result)
+		}
+		return nil, fmt.Errorf("PCM转换失败: %w", err)
+	}
+	utils.Info("%s解码为PCM完成", decoder.Name())
+	if err := stageError(ctx, encodeCtx, encodeErr, ErrEncodeTimeout); err != nil {
+		return nil, fmt.Errorf("%s转换失败: %w", strings.ToUpper(encoder.Ext()), err)
+	}
+	utils.Info("PCM通过%s编码完成, 参数: %s", encoder.Name(), opts.SilkOptions)
+
+	if split {
+		if opts.Progress != nil {
+			opts.Progress(100)
+		}
+		utils.Info("分段转换成功: %d段, 清单: %s (%s)", len(result.Parts), result.Filename, result.Name)
+		result.Filters = opts.FilterOptions.Filters()
+		return result, nil
+	}
+
+	// 原子地发布输出文件，下载接口永远不会读到写了一半的文件
+	if err := commitOutput(tmpPath, outputPath); err != nil {
+		utils.Error("输出文件未生成: %v", err)
+		return nil, fmt.Errorf("转换失败：输出文件未生成")
+	}
+
+	s.storeCache(cacheKey, outputFilename)
+	if opts.Progress != nil {
+		opts.Progress(100)
+	}
+	utils.Info("音频转换成功: %s (%s)", outputFilename, name)
+	result = s.publishResult(ctx, outputFilename, name, opts.NamingOptions, false)
+	result.Filters = opts.FilterOptions.Filters()
+	return result, nil
+}
+
+// pcmFormat 返回编码器输入的PCM格式，采样率依次取请求参数、服务配置和默认值
+func (s *AudioService) pcmFormat(opts SilkOptions) PCMFormat {
+	format := defaultPCMFormat
+	if opts.SampleRate > 0 {
+		format.SampleRate = opts.SampleRate
+	} else if s.SampleRate > 0 {
+		format.SampleRate = s.SampleRate
 	}
+	return format
+}
 
-	// 捕获命令输出以便记录
-	stdout, err = encoderCmd.StdoutPipe()
+// lookupCache 计算缓存键并查找缓存，返回缓存键和命中的文件名（未命中为空）
+func (s *AudioService) lookupCache(inputPath string, params ...string) (string, string) {
+	if s.Cache == nil {
+		return "", ""
+	}
+	key, err := CacheKey(inputPath, params...)
 	if err != nil {
-		os.Remove(pcmPath)
-		return "", fmt.Errorf("创建stdout管道失败: %v", err)
+		utils.Warn("计算缓存键失败: %v", err)
+		return "", ""
 	}
-	stderr, err = encoderCmd.StderrPipe()
+	if filename, ok := s.Cache.Lookup(key); ok {
+		utils.Info("命中转换缓存: %s", filename)
+		return key, filename
+	}
+	return key, ""
+}
+
+// storeCache 记录转换结果到缓存
+func (s *AudioService) storeCache(key, filename string) {
+	if s.Cache != nil && key != "" {
+		s.Cache.Store(key, filename)
+	}
+}
+
+// TempOutputSuffix 转换过程中输出文件的临时后缀
+const TempOutputSuffix = ".part"
+
+// tempOutputPath 返回输出文件对应的临时路径（隐藏文件 + .part 后缀）
+func tempOutputPath(outputPath string) string {
+	dir, name := filepath.Split(outputPath)
+	return filepath.Join(dir, "."+name+TempOutputSuffix)
+}
+
+// IsTempOutput 判断文件名是否为未完成的临时输出
+func IsTempOutput(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, TempOutputSuffix)
+}
+
+// commitOutput 检查临时输出并重命名为最终文件
+func commitOutput(tmpPath, outputPath string) error {
+	info, err := os.Stat(tmpPath)
 	if err != nil {
-		os.Remove(pcmPath)
-		return "", fmt.Errorf("创建stderr管道失败: %v", err)
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("输出文件为空")
 	}
+	return os.Rename(tmpPath, outputPath)
+}
 
-	utils.Debug("执行Encoder命令: %s", encoderCmd.String())
-	if err := encoderCmd.Start(); err != nil {
-		os.Remove(pcmPath)
-		return "", fmt.Errorf("启动encoder失败: %v", err)
+// ConvertFromSilk 将SILK语音解码为指定格式的音频（mp3、wav、ogg）
+func (s *AudioService) ConvertFromSilk(ctx context.Context, input Input, opts DecodeOptions) (*ConvertResult, error) {
+	defer s.DiscardInput(input)
+
+	format := strings.ToLower(strings.TrimPrefix(opts.Format, "."))
+	if format == "" {
+		format = "mp3"
+	}
+	encoder, err := s.Registry.Encoder(format)
+	if _, ok := findOutputFormat(format); !ok || err != nil {
+		return nil, fmt.Errorf("%w: 不支持的输出格式: %s", ErrInvalidOptions, format)
 	}
 
-	// 记录输出
-	go s.logOutput(stdout, false)
-	go s.logOutput(stderr, true)
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.release()
 
-	if err := encoderCmd.Wait(); err != nil {
-		os.Remove(pcmPath)
-		return "", fmt.Errorf("SILK转换失败: %v", err)
+	inputPath, cleanup, err := s.resolveInput(ctx, input)
+	if err != nil {
+		return nil, err
 	}
-	utils.Info("PCM转换为SILK完成")
+	defer cleanup()
 
-	// 清理临时文件
-	os.Remove(pcmPath)
-	os.Remove(inputPath)
-	utils.Debug("临时文件已清理")
+	cacheKey, cached := s.lookupCache(inputPath, "decode", format)
+	if cached != "" {
+		return s.reuseCached(ctx, cached, opts.NamingOptions)
+	}
+
+	outputFilename, name := newOutput(encoder.Ext(), opts.NamingOptions)
+	outputPath := filepath.Join(s.SilkDir, outputFilename)
+	tmpPath := tempOutputPath(outputPath)
+	utils.Debug("输出文件路径: %s", outputPath)
+	defer os.Remove(tmpPath)
+
+	// decoder将SILK解码为24kHz单声道PCM，通过管道直接交给与转换相同的ffmpeg编码器
+	encodeCtx, cancelEncode := stageContext(ctx, s.EncodeTimeout)
+	defer cancelEncode()
+
+	pr, pw := io.Pipe()
+	decodeErr := make(chan error, 1)
+	go func() {
+		err := s.decodeSILK(ctx, inputPath, pw)
+		pw.CloseWithError(err)
+		decodeErr <- err
+	}()
+	err = encoder.Encode(encodeCtx, pr, tmpPath, PCMFormat{SampleRate: silkPCMRate, Channels: 1})
+	// 编码器提前退出时关闭读端，避免decoder阻塞在写管道上
+	pr.CloseWithError(fmt.Errorf("编码器已退出"))
+	if err := <-decodeErr; err != nil {
+		return nil, err
+	}
+	if err := stageError(ctx, encodeCtx, err, ErrEncodeTimeout); err != nil {
+		return nil, fmt.Errorf("%s编码失败: %w", strings.ToUpper(format), err)
+	}
 
-	// 检查输出文件是否存在
-	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
+	if err := commitOutput(tmpPath, outputPath); err != nil {
 		utils.Error("输出文件未生成: %v", err)
-		return "", fmt.Errorf("转换失败：输出文件未生成")
+		return nil, fmt.Errorf("解码失败：输出文件未生成")
 	}
 
-	utils.Info("音频转换成功: %s", outputFilename)
-	return outputFilename, nil
+	s.storeCache(cacheKey, outputFilename)
+	utils.Info("SILK解码成功: %s (%s)", outputFilename, name)
+	return s.publishResult(ctx, outputFilename, name, opts.NamingOptions, false), nil
 }
 
-// logOutput 记录命令输出到日志
-func (s *AudioService) logOutput(r io.Reader, isError bool) {
-	buf := make([]byte, 1024)
-	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			output := strings.TrimSpace(string(buf[:n]))
-			if output != "" {
-				if isError {
-					utils.Debug("命令错误输出: %s", output)
-				} else {
-					utils.Debug("命令标准输出: %s", output)
-				}
-			}
-		}
-		if err != nil {
-			break
-		}
+// silkPCMRate 外部decoder输出PCM的采样率
+const silkPCMRate = 24000
+
+// decodeSILK 使用外部decoder将SILK文件解码为24kHz单声道PCM，写入w
+// 输入的文件头在读取时规范化（兼容带腾讯前缀的文件），文件内容流式交给decoder，不整体读入内存
+func (s *AudioService) decodeSILK(ctx context.Context, inputPath string, w io.Writer) error {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("读取SILK文件失败: %v", err)
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	header, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("读取SILK文件失败: %v", err)
+	}
+	if err := checkSILK(header); err != nil {
+		return err
+	}
+	in, err := silk.NormalizeReader(br)
+	if err != nil {
+		return err
 	}
-}
 
-// downloadFromURL 从URL下载文件
-func (s *AudioService) downloadFromURL(url string) (string, error) {
-	utils.Info("开始下载文件: %s", url)
+	decodeCtx, cancelDecode := stageContext(ctx, s.DecodeTimeout)
+	defer cancelDecode()
+	if runtime.GOOS == "windows" {
+		err = s.runDecoderFiles(decodeCtx, in, w)
+	} else {
+		err = s.runDecoderPipe(decodeCtx, in, w)
+	}
+	if err := stageError(ctx, decodeCtx, err, ErrDecodeTimeout); err != nil {
+		return fmt.Errorf("SILK解码失败: %w", err)
+	}
+	utils.Info("SILK解码为PCM完成")
+	return nil
+}
 
-	resp, err := http.Get(url)
+// runDecoderPipe 通过 /dev/stdin 向decoder输入SILK数据，从 /dev/fd/3 读取PCM
+// decoder会向标准输出打印解码信息，PCM不能走标准输出，因此使用额外的管道
+func (s *AudioService) runDecoderPipe(ctx context.Context, in io.Reader, w io.Writer) error {
+	pr, pw, err := os.Pipe()
 	if err != nil {
-		utils.Error("HTTP请求失败: %v", err)
-		return "", err
+		return err
+	}
+	copyErr := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(w, pr)
+		// 写入失败时关闭读端，decoder随即因管道断开退出
+		pr.Close()
+		copyErr <- err
+	}()
+
+	cmd := exec.CommandContext(ctx, s.DecoderPath, "/dev/stdin", "/dev/fd/3", "-Fs_API", strconv.Itoa(silkPCMRate))
+	cmd.Stdin = in
+	cmd.ExtraFiles = []*os.File{pw}
+	err = runCommand("Decoder", cmd)
+	// 关闭本进程持有的写端，读取协程才能读到EOF
+	pw.Close()
+	if cerr := <-copyErr; err == nil && cerr != nil {
+		err = fmt.Errorf("写入PCM失败: %v", cerr)
 	}
-	defer resp.Body.Close()
+	return err
+}
 
-	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), filepath.Ext(url))
-	filepath := filepath.Join(s.UploadDir, filename)
+// runDecoderFiles decoder只接受文件路径，Windows平台先将SILK和PCM写入上传目录下的临时文件
+func (s *AudioService) runDecoderFiles(ctx context.Context, in io.Reader, w io.Writer) error {
+	silkPath := s.UploadPath(".silk")
+	defer os.Remove(silkPath)
+	if err := writeFile(silkPath, in); err != nil {
+		return fmt.Errorf("写入SILK文件失败: %v", err)
+	}
+	utils.Debug("创建临时SILK文件: %s", silkPath)
+	pcmPath := s.UploadPath(".pcm")
+	defer os.Remove(pcmPath)
 
-	file, err := os.Create(filepath)
+	cmd := exec.CommandContext(ctx, s.DecoderPath, silkPath, pcmPath, "-Fs_API", strconv.Itoa(silkPCMRate))
+	if err := runCommand("Decoder", cmd); err != nil {
+		return err
+	}
+	pcm, err := os.Open(pcmPath)
 	if err != nil {
-		utils.Error("创建文件失败: %v", err)
-		return "", err
+		return fmt.Errorf("读取PCM失败: %v", err)
+	}
+	defer pcm.Close()
+	if _, err := io.Copy(w, pcm); err != nil {
+		return fmt.Errorf("写入PCM失败: %v", err)
 	}
-	defer file.Close()
+	return nil
+}
 
-	size, err := io.Copy(file, resp.Body)
-	if err != nil {
-		utils.Error("保存文件失败: %v", err)
-		return "", err
+// commandWaitDelay 外部命令被终止后等待其I/O结束的最长时间
+const commandWaitDelay = 2 * time.Second
+
+// runCommand 执行外部命令并将其输出记录到日志
+// 若调用方已设置cmd.Stdout（例如作为PCM输出管道），则只记录stderr
+func runCommand(name string, cmd *exec.Cmd) error {
+	return runCommandLines(name, cmd, nil)
+}
+
+// runCommandLines 执行外部命令，stderr按行交给onLine处理，onLine返回false的行记录到日志
+func runCommandLines(name string, cmd *exec.Cmd, onLine func(line string) bool) error {
+	var stdout *lineWriter
+	if cmd.Stdout == nil {
+		stdout = &lineWriter{handle: func(line string) {
+			utils.Debug("命令标准输出: %s", line)
+		}}
+		cmd.Stdout = stdout
 	}
+	stderr := &lineWriter{handle: func(line string) {
+		if onLine != nil && onLine(line) {
+			return
+		}
+		utils.Debug("命令错误输出: %s", line)
+	}}
+	cmd.Stderr = stderr
 
-	utils.Info("文件下载完成: %s (大小: %d 字节)", filename, size)
-	return filepath, nil
+	// 进程被ctx终止后，最多再等待commandWaitDelay让子进程释放管道
+	cmd.WaitDelay = commandWaitDelay
+
+	utils.Debug("执行%s命令: %s", name, cmd.String())
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动%s失败: %v", name, err)
+	}
+
+	err := cmd.Wait()
+	if stdout != nil {
+		stdout.Flush()
+	}
+	stderr.Flush()
+	return err
+}
+
+// lineWriter 将写入的数据按行（\n或\r）切分后交给handle处理
+type lineWriter struct {
+	handle func(line string)
+	buf    []byte
+}
+
+// Write 实现 io.Writer
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush 输出缓冲区中剩余的不完整行
+func (w *lineWriter) Flush() {
+	w.emit(w.buf)
+	w.buf = nil
 }
 
-// saveUploadedFile 保存上传的文件
-func (s *AudioService) saveUploadedFile(content []byte) (string, error) {
-	filename := fmt.Sprintf("%d.wav", time.Now().UnixNano())
-	filepath := filepath.Join(s.UploadDir, filename)
+// emit 处理一行非空输出
+func (w *lineWriter) emit(line []byte) {
+	if text := strings.TrimSpace(string(line)); text != "" {
+		w.handle(text)
+	}
+}
 
-	utils.Debug("保存上传文件: %s (大小: %d 字节)", filename, len(content))
+// downloadFromURL 从URL下载文件，扩展名由文件头识别结果决定，不依赖URL路径
+func (s *AudioService) downloadFromURL(ctx context.Context, url string) (string, error) {
+	utils.Info("开始下载文件: %s", url)
 
-	err := os.WriteFile(filepath, content, 0644)
+	var size int64
+	filepath, err := s.storeInput(func(w io.Writer) (err error) {
+		size, err = s.Fetcher.Fetch(ctx, url, w)
+		return err
+	})
 	if err != nil {
-		utils.Error("写入文件失败: %v", err)
+		utils.Error("下载文件失败: %v", err)
 		return "", err
 	}
 
+	utils.Info("文件下载完成: %s (大小: %d 字节)", getFileName(filepath), size)
 	return filepath, nil
 }
+
+// UploadPath 返回上传目录中带随机名称的临时文件路径，ext需包含点号
+func (s *AudioService) UploadPath(ext string) string {
+	return filepath.Join(s.UploadDir, newRandomID()+ext)
+}
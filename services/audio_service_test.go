@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStageContext(t *testing.T) {
+	ctx, cancel := stageContext(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("超时为0时不应设置截止时间")
+	}
+	ctx, cancel = stageContext(context.Background(), time.Minute)
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("应设置截止时间")
+	}
+}
+
+func TestStageError(t *testing.T) {
+	errTimeout := errors.New("阶段超时")
+	errFailed := errors.New("进程退出")
+
+	if err := stageError(context.Background(), context.Background(), nil, errTimeout); err != nil {
+		t.Errorf("无错误时 stageError = %v", err)
+	}
+
+	// 阶段超时
+	stage, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-stage.Done()
+	if err := stageError(context.Background(), stage, errFailed, errTimeout); err != errTimeout {
+		t.Errorf("阶段超时: stageError = %v, want %v", err, errTimeout)
+	}
+
+	// 调用方取消优先于阶段超时
+	parent, cancelParent := context.WithCancel(context.Background())
+	cancelParent()
+	if err := stageError(parent, stage, errFailed, errTimeout); !errors.Is(err, context.Canceled) {
+		t.Errorf("调用方取消: stageError = %v, want %v", err, context.Canceled)
+	}
+
+	// 其他错误原样返回
+	if err := stageError(context.Background(), context.Background(), errFailed, errTimeout); err != errFailed {
+		t.Errorf("stageError = %v, want %v", err, errFailed)
+	}
+}
+
+func TestDecodeSILKStreamsInput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用shell脚本模拟decoder")
+	}
+	dir := t.TempDir()
+	// 模拟decoder将输入原样作为PCM输出，并向标准输出打印信息
+	decoder := filepath.Join(dir, "decoder")
+	script := "#!/bin/sh\necho decoding\ncat \"$1\" > \"$2\"\n"
+	if err := os.WriteFile(decoder, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	silkPath := filepath.Join(dir, "a.silk")
+	if err := os.WriteFile(silkPath, []byte("\x02#!SILK_V3\x01\x00a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &AudioService{UploadDir: dir, DecoderPath: decoder}
+	var pcm bytes.Buffer
+	if err := s.decodeSILK(context.Background(), silkPath, &pcm); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pcm.String(), "#!SILK_V3\x01\x00a"; got != want {
+		t.Errorf("decoder收到 %q, want %q", got, want)
+	}
+
+	mp3Path := filepath.Join(dir, "a.mp3")
+	if err := os.WriteFile(mp3Path, []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.decodeSILK(context.Background(), mp3Path, &pcm); !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("decodeSILK(mp3) = %v, want %v", err, ErrUnsupportedMedia)
+	}
+}
@@ -0,0 +1,425 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"audio-converter/utils"
+)
+
+// FFmpegDecoder 使用ffmpeg解码任意音频
+type FFmpegDecoder struct {
+	Path string
+}
+
+// Name 返回解码器名称
+func (d *FFmpegDecoder) Name() string { return "ffmpeg" }
+
+// Decode 使用ffmpeg将音频转换为PCM，通过stdout输出
+func (d *FFmpegDecoder) Decode(ctx context.Context, inputPath string, format PCMFormat, w io.Writer) error {
+	return d.DecodeWithProgress(ctx, inputPath, format, w, nil)
+}
+
+// DecodeWithProgress 解码并通过 -progress 输出报告进度，progress为nil时不报告
+func (d *FFmpegDecoder) DecodeWithProgress(ctx context.Context, inputPath string, format PCMFormat, w io.Writer, progress ProgressFunc) error {
+	return d.DecodeWithFilters(ctx, inputPath, format, w, FilterOptions{}, progress)
+}
+
+// DecodeWithFilters 解码并通过 -af 应用音频处理滤镜
+func (d *FFmpegDecoder) DecodeWithFilters(ctx context.Context, inputPath string, format PCMFormat, w io.Writer, filters FilterOptions, progress ProgressFunc) error {
+	args := append(filters.inputArgs(), "-i", inputPath)
+	args = append(args, filters.mapArgs()...)
+	args = append(args, "-vn") // 视频文件只取音频
+	if chain := filters.Filters(); len(chain) > 0 {
+		args = append(args, "-af", strings.Join(chain, ","))
+	}
+	args = append(args,
+		"-f", "s16le", // 强制16位小端PCM格式
+		"-acodec", "pcm_s16le", // PCM 16位有符号整数小端格式
+		"-ar", strconv.Itoa(format.SampleRate), // 采样率
+		"-ac", strconv.Itoa(format.Channels)) // 声道数
+	if progress != nil {
+		args = append(args, "-progress", "pipe:2", "-nostats")
+	}
+	args = append(args, "pipe:1") // 输出到stdout
+
+	cmd := exec.CommandContext(ctx, d.Path, args...)
+	cmd.Stdout = w
+	if progress == nil {
+		return runCommand("FFmpeg", cmd)
+	}
+	p := &ffmpegProgress{report: progress, edit: filters.EditOptions}
+	return runCommandLines("FFmpeg", cmd, p.handleLine)
+}
+
+// SoxDecoder 使用sox解码音频
+type SoxDecoder struct {
+	Path string
+}
+
+// Name 返回解码器名称
+func (d *SoxDecoder) Name() string { return "sox" }
+
+// Decode 使用sox将音频转换为PCM，通过stdout输出
+func (d *SoxDecoder) Decode(ctx context.Context, inputPath string, format PCMFormat, w io.Writer) error {
+	cmd := exec.CommandContext(ctx, d.Path, inputPath,
+		"-t", "raw", "-e", "signed-integer", "-b", "16", "-L",
+		"-r", strconv.Itoa(format.SampleRate),
+		"-c", strconv.Itoa(format.Channels),
+		"-")
+	cmd.Stdout = w
+	return runCommand("Sox", cmd)
+}
+
+// WAVDecoder 纯Go实现的WAV读取器，仅支持16位PCM WAV
+type WAVDecoder struct{}
+
+// Name 返回解码器名称
+func (d *WAVDecoder) Name() string { return "wav" }
+
+// Decode 读取WAV文件，按需进行声道混合和线性重采样
+func (d *WAVDecoder) Decode(ctx context.Context, inputPath string, format PCMFormat, w io.Writer) error {
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return decodeWAV(bufio.NewReader(&contextReader{ctx: ctx, r: in}), w, format)
+}
+
+// wavHeader WAV fmt块中需要的字段
+type wavHeader struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	BitsPerSample uint16
+}
+
+// readWAVHeader 解析RIFF头并定位到data块，返回格式信息和data块长度
+func readWAVHeader(r io.Reader) (wavHeader, uint32, error) {
+	var hdr wavHeader
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return hdr, 0, fmt.Errorf("读取WAV文件头失败: %v", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return hdr, 0, fmt.Errorf("不是有效的WAV文件")
+	}
+
+	gotFmt := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return hdr, 0, fmt.Errorf("WAV文件缺少data块")
+		}
+		id := string(chunk[0:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			body := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, body); err != nil || size < 16 {
+				return hdr, 0, fmt.Errorf("WAV fmt块无效")
+			}
+			hdr.AudioFormat = binary.LittleEndian.Uint16(body[0:2])
+			hdr.Channels = binary.LittleEndian.Uint16(body[2:4])
+			hdr.SampleRate = binary.LittleEndian.Uint32(body[4:8])
+			hdr.BitsPerSample = binary.LittleEndian.Uint16(body[14:16])
+			// WAVE_FORMAT_EXTENSIBLE 的实际格式在子格式GUID的前两个字节
+			if hdr.AudioFormat == 0xFFFE && size >= 26 {
+				hdr.AudioFormat = binary.LittleEndian.Uint16(body[24:26])
+			}
+			gotFmt = true
+		case "data":
+			if !gotFmt {
+				return hdr, 0, fmt.Errorf("WAV文件缺少fmt块")
+			}
+			return hdr, size, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size+size%2)); err != nil {
+				return hdr, 0, fmt.Errorf("WAV文件缺少data块")
+			}
+		}
+	}
+}
+
+// writeWAVHeader 写出16位PCM WAV文件头，dataSize为data块长度
+func writeWAVHeader(w io.Writer, format PCMFormat, dataSize uint32) error {
+	blockAlign := format.Channels * 2
+	var hdr [44]byte
+	copy(hdr[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:8], 36+dataSize)
+	copy(hdr[8:16], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(hdr[16:20], 16)
+	binary.LittleEndian.PutUint16(hdr[20:22], 1)
+	binary.LittleEndian.PutUint16(hdr[22:24], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(hdr[24:28], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(hdr[28:32], uint32(format.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(hdr[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(hdr[34:36], 16)
+	copy(hdr[36:40], "data")
+	binary.LittleEndian.PutUint32(hdr[40:44], dataSize)
+	_, err := w.Write(hdr[:])
+	return err
+}
+
+// writeWAVFile 创建WAV文件，由write写入PCM数据，完成后回填文件头中的长度，返回PCM字节数
+// 失败时删除已创建的文件
+func writeWAVFile(path string, format PCMFormat, write func(w io.Writer) error) (n int64, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+		}
+	}()
+	// 先写入占位文件头，完成后回填data块长度
+	if err := writeWAVHeader(f, format, 0); err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(f)
+	out := &countingWriter{w: bw}
+	if err := write(out); err != nil {
+		return 0, err
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := writeWAVHeader(f, format, uint32(out.n)); err != nil {
+		return 0, err
+	}
+	return out.n, f.Close()
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// decodeWAV 将WAV数据转换为指定格式的PCM
+func decodeWAV(r io.Reader, w io.Writer, format PCMFormat) error {
+	hdr, dataSize, err := readWAVHeader(r)
+	if err != nil {
+		return err
+	}
+	if hdr.AudioFormat != 1 || hdr.BitsPerSample != 16 || hdr.Channels == 0 {
+		return fmt.Errorf("WAV解码器仅支持16位PCM，当前格式: format=%d bits=%d",
+			hdr.AudioFormat, hdr.BitsPerSample)
+	}
+	utils.Debug("WAV格式: %dHz, %d声道", hdr.SampleRate, hdr.Channels)
+
+	srcCh := int(hdr.Channels)
+	dstCh := format.Channels
+	rs := newLinearResampler(int(hdr.SampleRate), format.SampleRate, dstCh)
+
+	// 0xFFFFFFFF 或 0 通常表示流式写出的WAV，读取到文件末尾为止
+	var data io.Reader = r
+	if dataSize != 0 && dataSize != 0xFFFFFFFF {
+		data = io.LimitReader(r, int64(dataSize))
+	}
+
+	buf := make([]byte, 4096*srcCh*2)
+	out := make([]byte, 0, len(buf)*2)
+	for {
+		n, err := io.ReadFull(data, buf)
+		frames := n / (srcCh * 2)
+		if frames > 0 {
+			mixed := mixChannels(buf[:frames*srcCh*2], srcCh, dstCh)
+			out = rs.process(mixed, out[:0])
+			if _, werr := w.Write(out); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// mixChannels 将交错的16位PCM从srcCh声道转换为dstCh声道
+func mixChannels(pcm []byte, srcCh, dstCh int) []int16 {
+	frames := len(pcm) / (srcCh * 2)
+	result := make([]int16, frames*dstCh)
+	for i := 0; i < frames; i++ {
+		base := i * srcCh * 2
+		if srcCh == dstCh {
+			for c := 0; c < srcCh; c++ {
+				result[i*dstCh+c] = int16(binary.LittleEndian.Uint16(pcm[base+c*2:]))
+			}
+			continue
+		}
+		// 先混合为单声道，再复制到目标声道
+		sum := 0
+		for c := 0; c < srcCh; c++ {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[base+c*2:])))
+		}
+		v := int16(sum / srcCh)
+		for c := 0; c < dstCh; c++ {
+			result[i*dstCh+c] = v
+		}
+	}
+	return result
+}
+
+// linearResampler 流式线性插值重采样器
+type linearResampler struct {
+	channels int
+	step     float64 // 每个输出帧对应的输入帧步长
+	pos      float64 // 下一个输出帧在当前缓冲区中的位置
+	prev     []int16 // 上一块的最后一帧
+}
+
+// newLinearResampler 创建重采样器
+func newLinearResampler(srcRate, dstRate, channels int) *linearResampler {
+	return &linearResampler{
+		channels: channels,
+		step:     float64(srcRate) / float64(dstRate),
+	}
+}
+
+// process 重采样一块交错PCM帧，结果以小端字节追加到out
+func (rs *linearResampler) process(in []int16, out []byte) []byte {
+	ch := rs.channels
+	frames := append(append([]int16{}, rs.prev...), in...)
+	count := len(frames) / ch
+	if count == 0 {
+		return out
+	}
+
+	var sample [2]byte
+	for rs.pos < float64(count-1) {
+		i := int(rs.pos)
+		frac := rs.pos - float64(i)
+		for c := 0; c < ch; c++ {
+			a := float64(frames[i*ch+c])
+			b := float64(frames[(i+1)*ch+c])
+			binary.LittleEndian.PutUint16(sample[:], uint16(int16(a+(b-a)*frac)))
+			out = append(out, sample[:]...)
+		}
+		rs.pos += rs.step
+	}
+
+	// 保留最后一帧用于下一块的插值
+	rs.pos -= float64(count - 1)
+	rs.prev = append(rs.prev[:0], frames[(count-1)*ch:]...)
+	return out
+}
+
+// SilkEncoder 调用外部 silk-v3-decoder encoder 程序编码SILK
+type SilkEncoder struct {
+	Path    string
+	Tencent bool
+}
+
+// Name 返回编码器名称
+func (e *SilkEncoder) Name() string { return "encoder" }
+
+// Ext 返回输出文件扩展名
+func (e *SilkEncoder) Ext() string { return "silk" }
+
+// Encode 使用默认参数将PCM编码为SILK
+func (e *SilkEncoder) Encode(ctx context.Context, r io.Reader, outputPath string, format PCMFormat) error {
+	return e.EncodeSilk(ctx, r, outputPath, format, SilkOptions{})
+}
+
+// CheckSilkOptions 外部encoder支持全部SILK编码参数
+func (e *SilkEncoder) CheckSilkOptions(opts SilkOptions) error {
+	return nil
+}
+
+// EncodeSilk 使用外部encoder将PCM编码为SILK
+// encoder只接受文件路径，非Windows平台通过 /dev/stdin 直接读取管道，
+// Windows平台先将PCM写入输出目录下的临时文件
+func (e *SilkEncoder) EncodeSilk(ctx context.Context, r io.Reader, outputPath string, format PCMFormat, opts SilkOptions) error {
+	pcmPath := "/dev/stdin"
+	if runtime.GOOS == "windows" {
+		pcmPath = outputPath + ".pcm"
+		if err := writeFile(pcmPath, r); err != nil {
+			return err
+		}
+		defer os.Remove(pcmPath)
+		r = nil
+	}
+
+	cmd := exec.CommandContext(ctx, e.Path, e.args(pcmPath, outputPath, format, opts)...)
+	if r != nil {
+		cmd.Stdin = r
+	}
+	return runCommand("Encoder", cmd)
+}
+
+// args 返回encoder的命令行参数，未设置的编码参数不传，使用encoder默认值
+func (e *SilkEncoder) args(pcmPath, outputPath string, format PCMFormat, opts SilkOptions) []string {
+	args := []string{pcmPath, outputPath, "-Fs_API", strconv.Itoa(format.SampleRate)}
+	if opts.BitRate != 0 {
+		args = append(args, "-rate", strconv.Itoa(opts.BitRate))
+	}
+	if opts.PacketSize != 0 {
+		args = append(args, "-packetlength", strconv.Itoa(opts.PacketSize))
+	}
+	if opts.Complexity != nil {
+		args = append(args, "-complexity", strconv.Itoa(*opts.Complexity))
+	}
+	if opts.PacketLoss != 0 {
+		args = append(args, "-loss", strconv.Itoa(opts.PacketLoss))
+	}
+	if opts.DTX {
+		args = append(args, "-DTX", "1")
+	}
+	if opts.tencent(e.Tencent) {
+		args = append(args, "-tencent")
+	}
+	return args
+}
+
+// writeFile 将r的全部内容写入path
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// contextReader 在每次读取前检查ctx，使纯Go的解码循环可被取消
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read 实现 io.Reader
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
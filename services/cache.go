@@ -0,0 +1,143 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"audio-converter/utils"
+)
+
+// CacheIndexFile 缓存索引文件名（隐藏文件，不会出现在文件列表中，也不可下载）
+const CacheIndexFile = ".cache_index.json"
+
+// CacheEntry 缓存条目：输入内容与转换参数的哈希对应的输出文件
+type CacheEntry struct {
+	Filename  string    `json:"filename"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Cache 内容寻址的转换结果缓存，索引持久化在输出目录中
+type Cache struct {
+	dir  string
+	path string
+
+	mu      sync.Mutex
+	entries map[string]CacheEntry
+}
+
+// NewCache 创建缓存并加载输出目录中已有的索引
+func NewCache(dir string) *Cache {
+	c := &Cache{
+		dir:     dir,
+		path:    filepath.Join(dir, CacheIndexFile),
+		entries: make(map[string]CacheEntry),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err == nil {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			utils.Warn("缓存索引损坏，已忽略: %v", err)
+			c.entries = make(map[string]CacheEntry)
+		}
+	} else if !os.IsNotExist(err) {
+		utils.Warn("读取缓存索引失败: %v", err)
+	}
+
+	c.mu.Lock()
+	c.pruneLocked()
+	c.mu.Unlock()
+	utils.Info("转换缓存已加载: %d 条记录", len(c.entries))
+	return c
+}
+
+// CacheKey 计算输入文件内容与转换参数的SHA-256
+func CacheKey(inputPath string, params ...string) (string, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	// 参数以不可能出现在文件哈希中的分隔符拼接
+	h.Write([]byte("\x00" + strings.Join(params, "\x00")))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Lookup 查找缓存，命中时刷新输出文件的修改时间，使其按最后使用时间过期
+func (c *Cache) Lookup(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+
+	path := filepath.Join(c.dir, entry.Filename)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		// 输出文件已被清理，缓存失效
+		delete(c.entries, key)
+		c.saveLocked()
+		return "", false
+	}
+	return entry.Filename, true
+}
+
+// Store 记录转换结果
+func (c *Cache) Store(key, filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = CacheEntry{Filename: filename, CreatedAt: time.Now()}
+	c.saveLocked()
+}
+
+// Prune 删除输出文件已不存在的条目，返回删除数量
+func (c *Cache) Prune() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pruneLocked()
+}
+
+// pruneLocked 删除失效条目并保存索引，调用方需持有锁
+func (c *Cache) pruneLocked() int {
+	count := 0
+	for key, entry := range c.entries {
+		if _, err := os.Stat(filepath.Join(c.dir, entry.Filename)); err != nil {
+			delete(c.entries, key)
+			count++
+		}
+	}
+	if count > 0 {
+		c.saveLocked()
+	}
+	return count
+}
+
+// saveLocked 原子地写入索引文件，调用方需持有锁
+func (c *Cache) saveLocked() {
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		utils.Error("序列化缓存索引失败: %v", err)
+		return
+	}
+	tmp := c.path + TempOutputSuffix
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		utils.Error("写入缓存索引失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		utils.Error("保存缓存索引失败: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.mp3")
+	b := filepath.Join(dir, "b.mp3")
+	c := filepath.Join(dir, "c.mp3")
+	os.WriteFile(a, []byte("ID3same"), 0644)
+	os.WriteFile(b, []byte("ID3same"), 0644)
+	os.WriteFile(c, []byte("ID3other"), 0644)
+
+	key := func(path string, params ...string) string {
+		t.Helper()
+		k, err := CacheKey(path, params...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	if key(a, "encoder", "24000") != key(b, "encoder", "24000") {
+		t.Error("内容和参数相同时应得到相同的键")
+	}
+	if key(a, "encoder") == key(c, "encoder") {
+		t.Error("内容不同时键应不同")
+	}
+	if key(a, "encoder", "24000") == key(a, "encoder", "16000") {
+		t.Error("参数不同时键应不同")
+	}
+	// 参数之间有分隔符，拼接后相同的参数列表不应冲突
+	if key(a, "ab", "c") == key(a, "a", "bc") {
+		t.Error("参数边界不同时键应不同")
+	}
+	if _, err := CacheKey(filepath.Join(dir, "missing.mp3")); err == nil {
+		t.Error("输入文件不存在时应返回错误")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.silk")
+	os.WriteFile(output, []byte("#!SILK_V3"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(output, old, old)
+
+	c := NewCache(dir)
+	if _, ok := c.Lookup("k1"); ok {
+		t.Error("空缓存不应命中")
+	}
+	c.Store("k1", "out.silk")
+	c.Store("k2", "gone.silk")
+
+	filename, ok := c.Lookup("k1")
+	if !ok || filename != "out.silk" {
+		t.Errorf("Lookup(k1) = %q, %v", filename, ok)
+	}
+	// 命中时刷新修改时间，使输出按最后使用时间过期
+	if info, err := os.Stat(output); err != nil || !info.ModTime().After(old) {
+		t.Error("命中缓存后应刷新输出文件的修改时间")
+	}
+	if _, ok := c.Lookup("k2"); ok {
+		t.Error("输出文件不存在时不应命中")
+	}
+
+	// 索引持久化在输出目录中，重新加载时删除失效条目
+	c.Store("k3", "gone.silk")
+	reloaded := NewCache(dir)
+	if filename, ok := reloaded.Lookup("k1"); !ok || filename != "out.silk" {
+		t.Errorf("重新加载后 Lookup(k1) = %q, %v", filename, ok)
+	}
+	if _, ok := reloaded.Lookup("k3"); ok {
+		t.Error("重新加载时应删除失效条目")
+	}
+
+	os.Remove(output)
+	if n := reloaded.Prune(); n != 1 {
+		t.Errorf("Prune = %d, want 1", n)
+	}
+}
+
+func TestCacheCorruptIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, CacheIndexFile), []byte("{not json"), 0644)
+	c := NewCache(dir)
+	c.Store("k", "out.silk")
+	if _, err := os.Stat(filepath.Join(dir, CacheIndexFile)); err != nil {
+		t.Errorf("索引损坏时应重新写入: %v", err)
+	}
+}
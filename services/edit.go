@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 剪辑参数的取值范围
+const (
+	MinSpeed = 0.25
+	MaxSpeed = 4.0
+	MaxPitch = 12.0 // 变调范围（半音）
+
+	// atempo单级支持的倍速范围，超出时串联多级
+	atempoMin = 0.5
+	atempoMax = 2.0
+	// pitchRate 变调时的中间采样率
+	pitchRate = 48000
+	// rangeTolerance 检查剪辑范围时允许的误差（秒），探测的时长可能略短于实际解码时长
+	rangeTolerance = 0.05
+)
+
+// EditOptions 解码时的剪辑参数：截取片段、变速和变调，零值表示不剪辑
+type EditOptions struct {
+	Start    float64 `json:"start" form:"start"`       // 起始时间（秒）
+	End      float64 `json:"end" form:"end"`           // 结束时间（秒），0表示到结尾，不能与duration同时设置
+	Duration float64 `json:"duration" form:"duration"` // 截取时长（秒），0表示到结尾
+	Speed    float64 `json:"speed" form:"speed"`       // 播放速度倍数，0表示不变速，变速不改变音调
+	Pitch    float64 `json:"pitch" form:"pitch"`       // 音调偏移（半音），正数升调，变调不改变速度
+}
+
+// Validate 检查参数取值范围，不涉及输入文件
+func (o EditOptions) Validate() error {
+	if o.Start < 0 || o.End < 0 || o.Duration < 0 {
+		return fmt.Errorf("%w: start、end、duration 不能为负数", ErrInvalidOptions)
+	}
+	if o.End != 0 && o.Duration != 0 {
+		return fmt.Errorf("%w: end 与 duration 只能设置一个", ErrInvalidOptions)
+	}
+	if o.End != 0 && o.End <= o.Start {
+		return fmt.Errorf("%w: end 必须大于 start", ErrInvalidOptions)
+	}
+	if o.Speed != 0 && (o.Speed < MinSpeed || o.Speed > MaxSpeed) {
+		return fmt.Errorf("%w: speed 必须在 %g~%g 之间", ErrInvalidOptions, MinSpeed, MaxSpeed)
+	}
+	if o.Pitch < -MaxPitch || o.Pitch > MaxPitch {
+		return fmt.Errorf("%w: pitch 必须在 %g~%g 之间", ErrInvalidOptions, -MaxPitch, MaxPitch)
+	}
+	return nil
+}
+
+// hasRange 判断是否设置了截取范围
+func (o EditOptions) hasRange() bool {
+	return o.Start != 0 || o.End != 0 || o.Duration != 0
+}
+
+// length 返回截取片段的时长，到结尾时为0
+func (o EditOptions) length() float64 {
+	if o.End != 0 {
+		return o.End - o.Start
+	}
+	return o.Duration
+}
+
+// CheckRange 检查截取范围是否在输入时长之内
+func (o EditOptions) CheckRange(inputDuration float64) error {
+	if inputDuration <= 0 {
+		return nil
+	}
+	if o.Start >= inputDuration {
+		return fmt.Errorf("%w: start(%g秒) 超出输入时长 %.3f秒", ErrInvalidOptions, o.Start, inputDuration)
+	}
+	if end := o.Start + o.length(); o.length() != 0 && end > inputDuration+rangeTolerance {
+		return fmt.Errorf("%w: 截取范围 %g~%g秒 超出输入时长 %.3f秒", ErrInvalidOptions, o.Start, end, inputDuration)
+	}
+	return nil
+}
+
+// checkEditRange 设置了截取范围时读取输入时长并检查范围
+func (s *AudioService) checkEditRange(ctx context.Context, inputPath string, opts EditOptions) error {
+	if !opts.hasRange() {
+		return nil
+	}
+	info, err := s.ProbeFile(ctx, inputPath)
+	if err != nil {
+		return fmt.Errorf("读取输入时长失败: %w", err)
+	}
+	return opts.CheckRange(info.Duration)
+}
+
+// inputArgs 返回放在 -i 之前的ffmpeg参数，在输入端定位可避免解码被跳过的部分
+func (o EditOptions) inputArgs() []string {
+	var args []string
+	if o.Start != 0 {
+		args = append(args, "-ss", formatSeconds(o.Start))
+	}
+	if l := o.length(); l != 0 {
+		args = append(args, "-t", formatSeconds(l))
+	}
+	return args
+}
+
+// filters 返回变调和变速的滤镜
+// 变调先按比例提高采样率声明（音调和速度同时改变），再用atempo把速度还原并叠加变速
+func (o EditOptions) filters() []string {
+	var filters []string
+	tempo := o.speed()
+	if o.Pitch != 0 {
+		ratio := math.Pow(2, o.Pitch/12)
+		filters = append(filters,
+			fmt.Sprintf("aresample=%d", pitchRate),
+			fmt.Sprintf("asetrate=%d", int(math.Round(pitchRate*ratio))),
+			fmt.Sprintf("aresample=%d", pitchRate))
+		tempo /= ratio
+	}
+	return append(filters, atempoChain(tempo)...)
+}
+
+// speed 返回播放速度倍数，未设置时为1
+func (o EditOptions) speed() float64 {
+	if o.Speed == 0 {
+		return 1
+	}
+	return o.Speed
+}
+
+// outputDuration 根据输入时长估算剪辑后的输出时长，用于计算进度
+func (o EditOptions) outputDuration(input time.Duration) time.Duration {
+	d := input - time.Duration(o.Start*float64(time.Second))
+	if l := time.Duration(o.length() * float64(time.Second)); l != 0 && l < d {
+		d = l
+	}
+	if d < 0 {
+		return 0
+	}
+	return time.Duration(float64(d) / o.speed())
+}
+
+// atempoChain 将倍速拆分为多级atempo，每级在 0.5~2.0 之间
+func atempoChain(tempo float64) []string {
+	var chain []string
+	for tempo > atempoMax {
+		chain = append(chain, fmt.Sprintf("atempo=%g", atempoMax))
+		tempo /= atempoMax
+	}
+	for tempo < atempoMin {
+		chain = append(chain, fmt.Sprintf("atempo=%g", atempoMin))
+		tempo /= atempoMin
+	}
+	if math.Abs(tempo-1) > 1e-6 {
+		chain = append(chain, "atempo="+formatSeconds(math.Round(tempo*1e6)/1e6))
+	}
+	return chain
+}
+
+// formatSeconds 格式化ffmpeg参数中的小数，不带多余的0
+func formatSeconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
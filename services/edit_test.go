@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAtempoChain(t *testing.T) {
+	tests := []struct {
+		tempo float64
+		want  []string
+	}{
+		{1, nil},
+		{1.5, []string{"atempo=1.5"}},
+		{2, []string{"atempo=2"}},
+		{4, []string{"atempo=2", "atempo=2"}},
+		{3, []string{"atempo=2", "atempo=1.5"}},
+		{0.5, []string{"atempo=0.5"}},
+		{0.25, []string{"atempo=0.5", "atempo=0.5"}},
+		{0.3, []string{"atempo=0.5", "atempo=0.6"}},
+		{1.0000001, nil},
+	}
+	for _, tt := range tests {
+		if got := atempoChain(tt.tempo); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("atempoChain(%g) = %q, want %q", tt.tempo, got, tt.want)
+		}
+	}
+}
+
+func TestEditOptionsFilters(t *testing.T) {
+	tests := []struct {
+		opts EditOptions
+		want []string
+	}{
+		{EditOptions{}, nil},
+		{EditOptions{Speed: 1.25}, []string{"atempo=1.25"}},
+		// 升调12个半音：采样率声明加倍，再用atempo把速度还原
+		{EditOptions{Pitch: 12}, []string{"aresample=48000", "asetrate=96000", "aresample=48000", "atempo=0.5"}},
+		// 升调同时2倍速，两者抵消，不需要atempo
+		{EditOptions{Pitch: 12, Speed: 2}, []string{"aresample=48000", "asetrate=96000", "aresample=48000"}},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.filters(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filters(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestEditOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts EditOptions
+		ok   bool
+	}{
+		{EditOptions{}, true},
+		{EditOptions{Start: 1, End: 2.5, Speed: 4, Pitch: -12}, true},
+		{EditOptions{Start: 1, Duration: 3, Speed: MinSpeed}, true},
+		{EditOptions{Start: -1}, false},
+		{EditOptions{Duration: -1}, false},
+		{EditOptions{End: 2, Duration: 1}, false},
+		{EditOptions{Start: 2, End: 2}, false},
+		{EditOptions{Speed: 0.2}, false},
+		{EditOptions{Speed: 5}, false},
+		{EditOptions{Pitch: 12.5}, false},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("Validate(%+v) = %v", tt.opts, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestEditOptionsCheckRange(t *testing.T) {
+	tests := []struct {
+		opts EditOptions
+		ok   bool
+	}{
+		{EditOptions{Start: 5}, true},
+		{EditOptions{Start: 5, End: 10}, true},
+		{EditOptions{Start: 5, End: 10.04}, true},
+		{EditOptions{Start: 8, Duration: 2}, true},
+		{EditOptions{Start: 10}, false},
+		{EditOptions{Start: 5, End: 11}, false},
+		{EditOptions{Start: 8, Duration: 3}, false},
+	}
+	for _, tt := range tests {
+		err := tt.opts.CheckRange(10)
+		if tt.ok && err != nil {
+			t.Errorf("CheckRange(%+v) = %v", tt.opts, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("CheckRange(%+v) = %v, want %v", tt.opts, err, ErrInvalidOptions)
+		}
+	}
+	// 时长未知时不检查
+	if err := (EditOptions{Start: 100}).CheckRange(0); err != nil {
+		t.Errorf("CheckRange(时长未知) = %v", err)
+	}
+}
+
+func TestEditOptionsArgsAndDuration(t *testing.T) {
+	opts := EditOptions{Start: 1.5, End: 4}
+	if got, want := opts.inputArgs(), []string{"-ss", "1.5", "-t", "2.5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("inputArgs = %q, want %q", got, want)
+	}
+	if got := (EditOptions{}).inputArgs(); got != nil {
+		t.Errorf("inputArgs(零值) = %q", got)
+	}
+
+	tests := []struct {
+		opts EditOptions
+		want time.Duration
+	}{
+		{EditOptions{}, 10 * time.Second},
+		{EditOptions{Start: 4}, 6 * time.Second},
+		{EditOptions{Start: 4, Duration: 2}, 2 * time.Second},
+		{EditOptions{Start: 4, Speed: 2}, 3 * time.Second},
+		{EditOptions{Start: 20}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.outputDuration(10 * time.Second); got != tt.want {
+			t.Errorf("outputDuration(%+v) = %s, want %s", tt.opts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"audio-converter/utils"
+)
+
+var (
+	// ErrFetchScheme 不支持的URL协议
+	ErrFetchScheme = errors.New("只允许http/https协议")
+	// ErrFetchHostDenied 目标主机在禁止列表中
+	ErrFetchHostDenied = errors.New("目标主机被禁止访问")
+	// ErrFetchHostNotAllowed 目标主机不在允许列表中
+	ErrFetchHostNotAllowed = errors.New("目标主机不在允许列表中")
+	// ErrFetchPrivateAddress 目标解析到内网、回环或链路本地地址
+	ErrFetchPrivateAddress = errors.New("禁止访问内网地址")
+	// ErrFetchTooManyRedirects 重定向次数超过上限
+	ErrFetchTooManyRedirects = errors.New("重定向次数过多")
+	// ErrFetchTooLarge 响应体超过大小上限
+	ErrFetchTooLarge = errors.New("下载文件过大")
+	// ErrFetchStatus 远端返回非2xx状态码
+	ErrFetchStatus = errors.New("远端返回错误状态")
+	// ErrFetchTimeout 连接或读取超时
+	ErrFetchTimeout = errors.New("连接或读取超时")
+)
+
+// IsFetchRejected 判断错误是否为下载策略拒绝（协议、主机或地址不被允许）
+func IsFetchRejected(err error) bool {
+	return errors.Is(err, ErrFetchScheme) || errors.Is(err, ErrFetchHostDenied) ||
+		errors.Is(err, ErrFetchHostNotAllowed) || errors.Is(err, ErrFetchPrivateAddress)
+}
+
+// FetchPolicy URL下载策略
+type FetchPolicy struct {
+	// AllowHosts 允许访问的主机名或CIDR，非空时只允许列表中的目标；
+	// 主机名同时匹配其子域名，CIDR中的地址即使属于内网也允许访问
+	AllowHosts []string
+	// DenyHosts 禁止访问的主机名或CIDR，优先于允许列表
+	DenyHosts []string
+	// AllowPrivate 是否允许访问内网、回环和链路本地地址
+	AllowPrivate bool
+
+	MaxBytes       int64         // 响应体大小上限，0表示不限制
+	MaxRedirects   int           // 最大重定向次数，0表示不跟随重定向
+	ConnectTimeout time.Duration // 建立连接（含TLS握手）的超时
+	ReadTimeout    time.Duration // 等待响应头以及两次读取之间的最长间隔
+}
+
+// DefaultFetchPolicy 默认下载策略
+func DefaultFetchPolicy() FetchPolicy {
+	return FetchPolicy{
+		MaxBytes:       100 << 20,
+		MaxRedirects:   5,
+		ConnectTimeout: 10 * time.Second,
+		ReadTimeout:    30 * time.Second,
+	}
+}
+
+// Fetcher 按策略下载远程文件，在建立连接时检查目标地址以防止DNS重绑定
+type Fetcher struct {
+	policy FetchPolicy
+	allow  hostList
+	deny   hostList
+	client *http.Client
+}
+
+// NewFetcher 根据策略创建下载器
+func NewFetcher(policy FetchPolicy) (*Fetcher, error) {
+	allow, err := parseHostList(policy.AllowHosts)
+	if err != nil {
+		return nil, fmt.Errorf("解析允许列表失败: %v", err)
+	}
+	deny, err := parseHostList(policy.DenyHosts)
+	if err != nil {
+		return nil, fmt.Errorf("解析禁止列表失败: %v", err)
+	}
+
+	f := &Fetcher{policy: policy, allow: allow, deny: deny}
+	dialer := &net.Dialer{
+		Timeout: policy.ConnectTimeout,
+		Control: f.checkDial,
+	}
+	transport := &http.Transport{
+		// 不使用代理，否则连接检查只能看到代理地址
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   policy.ConnectTimeout,
+		ResponseHeaderTimeout: policy.ReadTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	f.client = &http.Client{
+		Transport:     transport,
+		CheckRedirect: f.checkRedirect,
+	}
+	return f, nil
+}
+
+// Fetch 下载URL内容写入w，返回写入的字节数
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, w io.Writer) (int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, fmt.Errorf("无效的URL: %v", err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return 0, err
+	}
+
+	// 读取间隔超时通过取消请求实现，每次读到数据后重新计时
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var idleTimeout atomic.Bool
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, f.requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("%w: %s", ErrFetchStatus, resp.Status)
+	}
+	if f.policy.MaxBytes > 0 && resp.ContentLength > f.policy.MaxBytes {
+		return 0, fmt.Errorf("%w: %d 字节，上限 %d 字节", ErrFetchTooLarge, resp.ContentLength, f.policy.MaxBytes)
+	}
+
+	var body io.Reader = resp.Body
+	if f.policy.ReadTimeout > 0 {
+		timer := time.AfterFunc(f.policy.ReadTimeout, func() {
+			idleTimeout.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+		body = &idleReader{r: resp.Body, timer: timer, timeout: f.policy.ReadTimeout}
+	}
+	if f.policy.MaxBytes > 0 {
+		body = io.LimitReader(body, f.policy.MaxBytes+1)
+	}
+
+	n, err := io.Copy(w, body)
+	if err != nil {
+		if idleTimeout.Load() {
+			return n, fmt.Errorf("%w: %v内未收到数据", ErrFetchTimeout, f.policy.ReadTimeout)
+		}
+		return n, err
+	}
+	if f.policy.MaxBytes > 0 && n > f.policy.MaxBytes {
+		return n, fmt.Errorf("%w: 超过上限 %d 字节", ErrFetchTooLarge, f.policy.MaxBytes)
+	}
+	return n, nil
+}
+
+// requestError 整理请求错误：保留上下文取消，连接和响应头超时转换为 ErrFetchTimeout
+func (f *Fetcher) requestError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %v", ErrFetchTimeout, err)
+	}
+	return err
+}
+
+// checkURL 检查URL协议和主机名
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %s", ErrFetchScheme, u.Scheme)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("无效的URL: 缺少主机名")
+	}
+	if f.deny.matchHost(host) {
+		return fmt.Errorf("%w: %s", ErrFetchHostDenied, host)
+	}
+	if !f.allow.empty() && !f.allow.matchHost(host) {
+		return fmt.Errorf("%w: %s", ErrFetchHostNotAllowed, host)
+	}
+	return nil
+}
+
+// checkRedirect 限制重定向次数，并对重定向目标重新执行URL检查
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.policy.MaxRedirects {
+		return fmt.Errorf("%w: 上限 %d 次", ErrFetchTooManyRedirects, f.policy.MaxRedirects)
+	}
+	utils.Debug("跟随重定向: %s", req.URL.Redacted())
+	return f.checkURL(req.URL)
+}
+
+// checkDial 在建立连接前检查实际连接的IP地址
+// DNS解析已经完成，检查结果与实际连接的地址一致，不受DNS重绑定影响
+func (f *Fetcher) checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("无法解析连接地址: %s", address)
+	}
+	if f.deny.matchIP(ip) {
+		return fmt.Errorf("%w: %s", ErrFetchHostDenied, ip)
+	}
+	if f.allow.matchIP(ip) {
+		return nil
+	}
+	if !f.policy.AllowPrivate && isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrFetchPrivateAddress, ip)
+	}
+	return nil
+}
+
+// idleReader 每次读到数据时重置读取间隔计时器
+type idleReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// hostList 主机名和CIDR列表
+type hostList struct {
+	names []string
+	nets  []*net.IPNet
+}
+
+// parseHostList 解析主机名或CIDR列表，单个IP按/32或/128处理
+func parseHostList(entries []string) (hostList, error) {
+	var l hostList
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return l, fmt.Errorf("无效的CIDR: %s", entry)
+			}
+			l.nets = append(l.nets, ipNet)
+			continue
+		}
+		if ip := net.ParseIP(strings.Trim(entry, "[]")); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			l.nets = append(l.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		l.names = append(l.names, strings.TrimSuffix(entry, "."))
+	}
+	return l, nil
+}
+
+func (l hostList) empty() bool {
+	return len(l.names) == 0 && len(l.nets) == 0
+}
+
+// matchHost 匹配主机名（含子域名）或IP字面量
+func (l hostList) matchHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return l.matchIP(ip)
+	}
+	for _, name := range l.names {
+		if host == name || strings.HasSuffix(host, "."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchIP 匹配IP地址
+func (l hostList) matchIP(ip net.IP) bool {
+	for _, ipNet := range l.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedNets 标准库未覆盖的保留地址段
+var reservedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // 本网络
+		"100.64.0.0/10", // 运营商级NAT
+		"192.0.0.0/24",  // IETF协议分配
+		"198.18.0.0/15", // 基准测试
+		"240.0.0.0/4",   // 保留
+		"64:ff9b::/96",  // NAT64，可能映射到内网IPv4
+	} {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		nets = append(nets, ipNet)
+	}
+	return nets
+}()
+
+// isPrivateIP 判断是否为内网、回环、链路本地或其他不可公网访问的地址
+func isPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range reservedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"198.18.0.1", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"64:ff9b::a00:1", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := isPrivateIP(net.ParseIP(tt.ip)); got != tt.private {
+			t.Errorf("isPrivateIP(%s) = %v, want %v", tt.ip, got, tt.private)
+		}
+	}
+}
+
+func TestParseHostList(t *testing.T) {
+	l, err := parseHostList([]string{" Example.COM ", "*.cdn.net", "10.0.0.0/8", "192.0.2.7", "[2001:db8::1]", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := map[string]bool{
+		"example.com":     true,
+		"a.example.com":   true,
+		"badexample.com":  false,
+		"cdn.net":         true,
+		"img.cdn.net":     true,
+		"10.20.30.40":     true,
+		"192.0.2.7":       true,
+		"192.0.2.8":       false,
+		"2001:db8::1":     true,
+		"other.org":       false,
+		"example.com.cn":  false,
+		"11.0.0.1":        false,
+		"2001:db8::2":     false,
+		"sub.sub.cdn.net": true,
+	}
+	for host, want := range hosts {
+		if got := l.matchHost(host); got != want {
+			t.Errorf("matchHost(%s) = %v, want %v", host, got, want)
+		}
+	}
+
+	if _, err := parseHostList([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("无效的CIDR应返回错误")
+	}
+}
+
+func TestFetcherCheckURL(t *testing.T) {
+	f, err := NewFetcher(FetchPolicy{
+		AllowHosts: []string{"example.com", "203.0.113.0/24"},
+		DenyHosts:  []string{"bad.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"http://example.com/a.mp3", nil},
+		{"https://cdn.example.com/a.mp3", nil},
+		{"http://EXAMPLE.com./a.mp3", nil},
+		{"http://203.0.113.5/a.mp3", nil},
+		{"ftp://example.com/a.mp3", ErrFetchScheme},
+		{"file:///etc/passwd", ErrFetchScheme},
+		{"http://bad.example.com/a.mp3", ErrFetchHostDenied},
+		{"http://x.bad.example.com/a.mp3", ErrFetchHostDenied},
+		{"http://other.org/a.mp3", ErrFetchHostNotAllowed},
+		{"http://198.51.100.1/a.mp3", ErrFetchHostNotAllowed},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.checkURL(u)
+		if tt.want == nil && err != nil {
+			t.Errorf("checkURL(%s) = %v, want nil", tt.url, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("checkURL(%s) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestFetcherCheckDial(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  FetchPolicy
+		address string
+		want    error
+	}{
+		{"公网地址", FetchPolicy{}, "93.184.216.34:80", nil},
+		{"回环地址", FetchPolicy{}, "127.0.0.1:80", ErrFetchPrivateAddress},
+		{"云元数据地址", FetchPolicy{}, "169.254.169.254:80", ErrFetchPrivateAddress},
+		{"IPv6回环", FetchPolicy{}, "[::1]:443", ErrFetchPrivateAddress},
+		{"允许内网", FetchPolicy{AllowPrivate: true}, "10.0.0.1:80", nil},
+		{"允许列表中的内网段", FetchPolicy{AllowHosts: []string{"10.0.0.0/8"}}, "10.0.0.1:80", nil},
+		{"禁止列表优先", FetchPolicy{AllowPrivate: true, DenyHosts: []string{"10.0.0.1"}}, "10.0.0.1:80", ErrFetchHostDenied},
+	}
+	for _, tt := range tests {
+		f, err := NewFetcher(tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.checkDial("tcp", tt.address, nil)
+		if tt.want == nil && err != nil {
+			t.Errorf("%s: checkDial(%s) = %v, want nil", tt.name, tt.address, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkDial(%s) = %v, want %v", tt.name, tt.address, err, tt.want)
+		}
+	}
+}
+
+func TestFetcherFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ID3audio"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 2048))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	policy := DefaultFetchPolicy()
+	policy.AllowPrivate = true
+	policy.MaxBytes = 1024
+	policy.MaxRedirects = 2
+	f, err := NewFetcher(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if n, err := f.Fetch(context.Background(), server.URL+"/ok", &buf); err != nil || n != 8 || buf.String() != "ID3audio" {
+		t.Errorf("Fetch(/ok) = %d, %v, %q", n, err, buf.String())
+	}
+	tests := map[string]error{
+		"/large":    ErrFetchTooLarge,
+		"/missing":  ErrFetchStatus,
+		"/redirect": ErrFetchTooManyRedirects,
+	}
+	for path, want := range tests {
+		_, err := f.Fetch(context.Background(), server.URL+path, &bytes.Buffer{})
+		if !errors.Is(err, want) {
+			t.Errorf("Fetch(%s) = %v, want %v", path, err, want)
+		}
+	}
+
+	// 默认策略禁止访问回环地址，测试服务器监听在127.0.0.1
+	f, err = NewFetcher(DefaultFetchPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Fetch(context.Background(), server.URL+"/ok", &bytes.Buffer{})
+	if !errors.Is(err, ErrFetchPrivateAddress) {
+		t.Errorf("Fetch(回环地址) = %v, want %v", err, ErrFetchPrivateAddress)
+	}
+}
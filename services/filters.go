@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 音频处理参数的默认值和取值范围
+const (
+	DefaultTargetLUFS = -16.0 // 语音常用的响度目标
+	MinTargetLUFS     = -70.0
+	MaxTargetLUFS     = -5.0
+	MaxGainDB         = 30.0
+	MinFilterFreq     = 20
+	MaxFilterFreq     = 20000
+
+	// trimThreshold 首尾静音判定阈值
+	trimThreshold = "-50dB"
+	// trimDuration 短于该时长的停顿不视为静音
+	trimDuration = "0.2"
+)
+
+// FilterOptions 解码阶段的音频处理选项，零值表示不做任何处理
+type FilterOptions struct {
+	Loudnorm    bool    `json:"loudnorm" form:"loudnorm"`         // EBU R128 响度标准化
+	TargetLUFS  float64 `json:"target_lufs" form:"target_lufs"`   // 响度目标(LUFS)，0表示-16
+	TrimSilence bool    `json:"trim_silence" form:"trim_silence"` // 去除开头和结尾的静音
+	HighPass    int     `json:"highpass" form:"highpass"`         // 高通滤波截止频率(Hz)，0表示不滤波
+	LowPass     int     `json:"lowpass" form:"lowpass"`           // 低通滤波截止频率(Hz)，0表示不滤波
+	Gain        float64 `json:"gain" form:"gain"`                 // 增益(dB)，在响度标准化之后应用
+	AudioStream *int    `json:"audio_stream" form:"audio_stream"` // 视频或多音轨文件中要转换的音频流（从0开始），为空时自动选择
+	EditOptions
+}
+
+// FilterDecoder 可选接口：支持在解码时进行音频处理的解码器
+type FilterDecoder interface {
+	// DecodeWithFilters 解码并按filters处理音频，progress为nil时不报告进度
+	DecodeWithFilters(ctx context.Context, inputPath string, format PCMFormat, w io.Writer, filters FilterOptions, progress ProgressFunc) error
+}
+
+// Validate 检查参数取值范围
+func (o FilterOptions) Validate() error {
+	if err := o.EditOptions.Validate(); err != nil {
+		return err
+	}
+	if o.TargetLUFS != 0 {
+		if !o.Loudnorm {
+			return fmt.Errorf("%w: target_lufs 需要同时设置 loudnorm", ErrInvalidOptions)
+		}
+		if o.TargetLUFS < MinTargetLUFS || o.TargetLUFS > MaxTargetLUFS {
+			return fmt.Errorf("%w: target_lufs 必须在 %g~%g 之间", ErrInvalidOptions, MinTargetLUFS, MaxTargetLUFS)
+		}
+	}
+	if o.HighPass != 0 && (o.HighPass < MinFilterFreq || o.HighPass > MaxFilterFreq) {
+		return fmt.Errorf("%w: highpass 必须在 %d~%d 之间", ErrInvalidOptions, MinFilterFreq, MaxFilterFreq)
+	}
+	if o.LowPass != 0 && (o.LowPass < MinFilterFreq || o.LowPass > MaxFilterFreq) {
+		return fmt.Errorf("%w: lowpass 必须在 %d~%d 之间", ErrInvalidOptions, MinFilterFreq, MaxFilterFreq)
+	}
+	if o.HighPass != 0 && o.LowPass != 0 && o.HighPass >= o.LowPass {
+		return fmt.Errorf("%w: highpass 必须小于 lowpass", ErrInvalidOptions)
+	}
+	if o.AudioStream != nil && *o.AudioStream < 0 {
+		return fmt.Errorf("%w: audio_stream 不能为负数", ErrInvalidOptions)
+	}
+	if o.Gain < -MaxGainDB || o.Gain > MaxGainDB {
+		return fmt.Errorf("%w: gain 必须在 %g~%g 之间", ErrInvalidOptions, -MaxGainDB, MaxGainDB)
+	}
+	return nil
+}
+
+// IsZero 判断是否未启用任何处理
+func (o FilterOptions) IsZero() bool {
+	return len(o.Filters()) == 0 && len(o.inputArgs()) == 0 && o.AudioStream == nil
+}
+
+// mapArgs 返回选择音频流的ffmpeg参数，未指定时由ffmpeg自动选择
+func (o FilterOptions) mapArgs() []string {
+	if o.AudioStream == nil {
+		return nil
+	}
+	return []string{"-map", fmt.Sprintf("0:a:%d", *o.AudioStream)}
+}
+
+// targetLUFS 返回响度目标，未设置时使用默认值
+func (o FilterOptions) targetLUFS() float64 {
+	if o.TargetLUFS == 0 {
+		return DefaultTargetLUFS
+	}
+	return o.TargetLUFS
+}
+
+// Filters 按处理顺序返回ffmpeg滤镜：先滤波和去除静音，再变调变速、做响度标准化，最后应用增益
+// 截取范围通过输入参数实现，不在其中
+func (o FilterOptions) Filters() []string {
+	var filters []string
+	if o.HighPass != 0 {
+		filters = append(filters, fmt.Sprintf("highpass=f=%d", o.HighPass))
+	}
+	if o.LowPass != 0 {
+		filters = append(filters, fmt.Sprintf("lowpass=f=%d", o.LowPass))
+	}
+	if o.TrimSilence {
+		// silenceremove只能去除开头的静音，结尾的静音通过反转音频后再去除一次
+		trim := fmt.Sprintf("silenceremove=start_periods=1:start_threshold=%s:start_duration=%s", trimThreshold, trimDuration)
+		filters = append(filters, trim, "areverse", trim, "areverse")
+	}
+	filters = append(filters, o.EditOptions.filters()...)
+	if o.Loudnorm {
+		filters = append(filters, fmt.Sprintf("loudnorm=I=%g:TP=-1.5:LRA=11", o.targetLUFS()))
+	}
+	if o.Gain != 0 {
+		filters = append(filters, fmt.Sprintf("volume=%gdB", o.Gain))
+	}
+	return filters
+}
+
+// cacheParams 返回参与缓存键计算的参数，未启用处理时为空以保持原有缓存键不变
+func (o FilterOptions) cacheParams() []string {
+	if o.IsZero() {
+		return nil
+	}
+	params := []string{"input:" + strings.Join(o.inputArgs(), " "), "filters:" + strings.Join(o.Filters(), ",")}
+	if o.AudioStream != nil {
+		params = append(params, "map:"+strings.Join(o.mapArgs(), " "))
+	}
+	return params
+}
+
+// checkDecoderFilters 检查解码器是否支持给定的音频处理选项
+func checkDecoderFilters(decoder Decoder, opts FilterOptions) error {
+	if _, ok := decoder.(FilterDecoder); ok || opts.IsZero() {
+		return nil
+	}
+	return fmt.Errorf("%w: 解码器%s不支持音频处理参数", ErrInvalidOptions, decoder.Name())
+}
+
+// decodeInput 按选项选择解码方式：需要音频处理或报告进度时使用解码器的可选接口
+func decodeInput(ctx context.Context, decoder Decoder, inputPath string, format PCMFormat, w io.Writer, opts ConvertOptions) error {
+	if fd, ok := decoder.(FilterDecoder); ok && !opts.FilterOptions.IsZero() {
+		return fd.DecodeWithFilters(ctx, inputPath, format, w, opts.FilterOptions, opts.Progress)
+	}
+	if pd, ok := decoder.(ProgressDecoder); ok && opts.Progress != nil {
+		return pd.DecodeWithProgress(ctx, inputPath, format, w, opts.Progress)
+	}
+	return decoder.Decode(ctx, inputPath, format, w)
+}
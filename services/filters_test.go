@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterOptionsFilters(t *testing.T) {
+	trim := "silenceremove=start_periods=1:start_threshold=-50dB:start_duration=0.2"
+	tests := []struct {
+		name string
+		opts FilterOptions
+		want []string
+	}{
+		{"不处理", FilterOptions{}, nil},
+		{"响度标准化", FilterOptions{Loudnorm: true}, []string{"loudnorm=I=-16:TP=-1.5:LRA=11"}},
+		{"响度目标", FilterOptions{Loudnorm: true, TargetLUFS: -23}, []string{"loudnorm=I=-23:TP=-1.5:LRA=11"}},
+		// 只去除首尾的静音：去除开头后反转音频，再去除一次，中间的停顿不受影响
+		{"去除首尾静音", FilterOptions{TrimSilence: true}, []string{trim, "areverse", trim, "areverse"}},
+		{"滤波", FilterOptions{HighPass: 80, LowPass: 8000}, []string{"highpass=f=80", "lowpass=f=8000"}},
+		{"增益", FilterOptions{Gain: -3.5}, []string{"volume=-3.5dB"}},
+		{"全部", FilterOptions{
+			Loudnorm: true, TrimSilence: true, HighPass: 100, LowPass: 7000, Gain: 2,
+			EditOptions: EditOptions{Speed: 1.5},
+		}, []string{
+			"highpass=f=100", "lowpass=f=7000",
+			trim, "areverse", trim, "areverse",
+			"atempo=1.5",
+			"loudnorm=I=-16:TP=-1.5:LRA=11",
+			"volume=2dB",
+		}},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.Filters(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Filters =\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+	for _, f := range (FilterOptions{TrimSilence: true}).Filters() {
+		if strings.Contains(f, "stop_periods") {
+			t.Errorf("去除静音不应处理中间的停顿: %s", f)
+		}
+	}
+}
+
+func TestFilterOptionsValidate(t *testing.T) {
+	stream := func(i int) *int { return &i }
+	tests := []struct {
+		opts FilterOptions
+		ok   bool
+	}{
+		{FilterOptions{}, true},
+		{FilterOptions{Loudnorm: true, TargetLUFS: -23, HighPass: 80, LowPass: 8000, Gain: 30}, true},
+		{FilterOptions{AudioStream: stream(1)}, true},
+		{FilterOptions{TargetLUFS: -23}, false},
+		{FilterOptions{Loudnorm: true, TargetLUFS: -80}, false},
+		{FilterOptions{Loudnorm: true, TargetLUFS: -1}, false},
+		{FilterOptions{HighPass: 10}, false},
+		{FilterOptions{LowPass: 30000}, false},
+		{FilterOptions{HighPass: 8000, LowPass: 8000}, false},
+		{FilterOptions{Gain: 31}, false},
+		{FilterOptions{AudioStream: stream(-1)}, false},
+		{FilterOptions{EditOptions: EditOptions{Speed: 10}}, false},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("Validate(%+v) = %v", tt.opts, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestFilterOptionsCacheParams(t *testing.T) {
+	if params := (FilterOptions{}).cacheParams(); params != nil {
+		t.Errorf("未启用处理时 cacheParams = %q, 应为空以保持原有缓存键", params)
+	}
+	a := FilterOptions{Loudnorm: true}.cacheParams()
+	b := FilterOptions{Loudnorm: true, Gain: 1}.cacheParams()
+	if reflect.DeepEqual(a, b) {
+		t.Error("处理参数不同时 cacheParams 应不同")
+	}
+	c := FilterOptions{EditOptions: EditOptions{Start: 1}}.cacheParams()
+	d := FilterOptions{EditOptions: EditOptions{Start: 2}}.cacheParams()
+	if reflect.DeepEqual(c, d) {
+		t.Error("截取范围不同时 cacheParams 应不同")
+	}
+}
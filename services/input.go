@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"audio-converter/utils"
+)
+
+var (
+	// ErrInvalidInput 输入不合法（URL协议错误、路径越界等）
+	ErrInvalidInput = errors.New("无效的输入")
+	// ErrImportDisabled 未配置导入目录，不允许使用服务器本地文件
+	ErrImportDisabled = errors.New("未启用服务器本地文件导入")
+)
+
+// Input 转换输入，只能是 UploadInput、RemoteURLInput 或 ServerFileInput
+type Input interface {
+	// Source 返回用于日志的输入描述
+	Source() string
+	// validate 检查输入是否合法，不访问网络
+	validate(s *AudioService) error
+	// resolve 将输入解析为本地文件路径，owned为true时调用方负责删除
+	resolve(ctx context.Context, s *AudioService) (path string, owned bool, err error)
+}
+
+// UploadInput 已保存到上传目录的上传文件，转换结束后删除
+type UploadInput struct {
+	Path string
+}
+
+// Source 返回上传文件名
+func (in UploadInput) Source() string {
+	return filepath.Base(in.Path)
+}
+
+func (in UploadInput) validate(s *AudioService) error {
+	if !withinDir(s.UploadDir, in.Path) {
+		return fmt.Errorf("%w: 上传文件不在上传目录中", ErrInvalidInput)
+	}
+	return nil
+}
+
+func (in UploadInput) resolve(_ context.Context, s *AudioService) (string, bool, error) {
+	if err := in.validate(s); err != nil {
+		return "", false, err
+	}
+	utils.Debug("使用上传文件: %s", in.Path)
+	return in.Path, true, nil
+}
+
+// RemoteURLInput 远程URL，通过 Fetcher 下载到上传目录
+type RemoteURLInput struct {
+	URL string
+}
+
+// Source 返回URL
+func (in RemoteURLInput) Source() string {
+	return in.URL
+}
+
+func (in RemoteURLInput) validate(_ *AudioService) error {
+	u, err := url.Parse(in.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: URL格式错误", ErrInvalidInput)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %v: %s", ErrInvalidInput, ErrFetchScheme, u.Scheme)
+	}
+	return nil
+}
+
+func (in RemoteURLInput) resolve(ctx context.Context, s *AudioService) (string, bool, error) {
+	if err := in.validate(s); err != nil {
+		return "", false, err
+	}
+	dlCtx, cancel := stageContext(ctx, s.DownloadTimeout)
+	defer cancel()
+	path, err := s.downloadFromURL(dlCtx, in.URL)
+	if err = stageError(ctx, dlCtx, err, ErrDownloadTimeout); err != nil {
+		utils.Error("下载URL失败: %v", err)
+		return "", false, err
+	}
+	utils.Info("已下载文件: %s", path)
+	return path, true, nil
+}
+
+// ServerFileInput 导入目录中的服务器本地文件，Name为相对导入目录的路径
+// 只有配置了 ImportDir 时可用，转换结束后不会删除
+type ServerFileInput struct {
+	Name string
+}
+
+// Source 返回相对路径
+func (in ServerFileInput) Source() string {
+	return in.Name
+}
+
+func (in ServerFileInput) validate(s *AudioService) error {
+	_, err := in.path(s)
+	return err
+}
+
+func (in ServerFileInput) resolve(_ context.Context, s *AudioService) (string, bool, error) {
+	path, err := in.path(s)
+	if err != nil {
+		return "", false, err
+	}
+	utils.Debug("使用导入目录文件: %s", path)
+	return path, false, nil
+}
+
+// path 返回导入文件的真实路径，拒绝绝对路径、越出导入目录的路径和符号链接逃逸
+func (in ServerFileInput) path(s *AudioService) (string, error) {
+	if s.ImportDir == "" {
+		return "", ErrImportDisabled
+	}
+	name := filepath.FromSlash(in.Name)
+	if name == "" || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: 必须是导入目录内的相对路径", ErrInvalidInput)
+	}
+
+	root, err := filepath.EvalSymlinks(s.ImportDir)
+	if err != nil {
+		return "", fmt.Errorf("导入目录不可用: %v", err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", fmt.Errorf("%w: 文件不存在: %s", ErrInvalidInput, in.Name)
+	}
+	if !withinDir(root, path) {
+		return "", fmt.Errorf("%w: 路径越出导入目录: %s", ErrInvalidInput, in.Name)
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: 不是普通文件: %s", ErrInvalidInput, in.Name)
+	}
+	return path, nil
+}
+
+// withinDir 判断path是否位于dir之内（不含dir本身）
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// ValidateInput 在提交转换前检查输入，错误可直接返回给客户端
+func (s *AudioService) ValidateInput(in Input) error {
+	if in == nil {
+		return fmt.Errorf("%w: 缺少输入或指定了多个输入", ErrInvalidInput)
+	}
+	return in.validate(s)
+}
+
+// CheckInput 检查本地输入的内容是否为可识别的音频格式，用于在占用转换槽位前尽早拒绝
+// 远程URL要下载后才能检查，这里直接放行
+func (s *AudioService) CheckInput(in Input) error {
+	var path string
+	switch in := in.(type) {
+	case UploadInput:
+		path = in.Path
+	case ServerFileInput:
+		p, err := in.path(s)
+		if err != nil {
+			return err
+		}
+		path = p
+	default:
+		return nil
+	}
+	header, err := readHeader(path)
+	if err != nil {
+		return err
+	}
+	_, err = checkAudio(header)
+	return err
+}
+
+// DiscardInput 删除上传输入，远程URL和导入目录中的文件不受影响
+func (s *AudioService) DiscardInput(in Input) {
+	if up, ok := in.(UploadInput); ok && withinDir(s.UploadDir, up.Path) {
+		os.Remove(up.Path)
+	}
+}
+
+// SaveUpload 将上传内容保存到上传目录，扩展名由文件头识别结果决定
+// 内容明显不是音频时返回 ErrUnsupportedMedia
+func (s *AudioService) SaveUpload(r io.Reader) (UploadInput, error) {
+	path, err := s.storeInput(func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		utils.Error("保存上传文件失败: %v", err)
+		return UploadInput{}, err
+	}
+	utils.Debug("已保存上传文件: %s", path)
+	return UploadInput{Path: path}, nil
+}
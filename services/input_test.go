@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithinDir(t *testing.T) {
+	dir := filepath.FromSlash("/data/uploads")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/data/uploads/a.mp3", true},
+		{"/data/uploads/sub/a.mp3", true},
+		{"/data/uploads", false},
+		{"/data/uploads/", false},
+		{"/data/uploads/../a.mp3", false},
+		{"/data/uploads-other/a.mp3", false},
+		{"/data/a.mp3", false},
+		{"/data/uploads/..a.mp3", true},
+	}
+	for _, tt := range tests {
+		if got := withinDir(dir, filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("withinDir(%s, %s) = %v, want %v", dir, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestServerFileInputPath(t *testing.T) {
+	root := t.TempDir()
+	importDir := filepath.Join(root, "import")
+	outside := filepath.Join(root, "secret.mp3")
+	mustWrite(t, filepath.Join(importDir, "2024", "voice.mp3"))
+	mustWrite(t, outside)
+	if err := os.Symlink(outside, filepath.Join(importDir, "escape.mp3")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(importDir, "2024", "voice.mp3"), filepath.Join(importDir, "inside.mp3")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &AudioService{ImportDir: importDir}
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"2024/voice.mp3", nil},
+		{"2024/../2024/voice.mp3", nil},
+		{"inside.mp3", nil},
+		{"", ErrInvalidInput},
+		{outside, ErrInvalidInput},
+		{"../secret.mp3", ErrInvalidInput},
+		{"2024/../../secret.mp3", ErrInvalidInput},
+		{"escape.mp3", ErrInvalidInput},
+		{"2024", ErrInvalidInput},
+		{"missing.mp3", ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		path, err := ServerFileInput{Name: tt.name}.path(s)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("path(%q) = %v, want nil", tt.name, err)
+			} else if !withinDir(importDir, path) && !withinDir(mustEval(t, importDir), path) {
+				t.Errorf("path(%q) = %s, 不在导入目录中", tt.name, path)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("path(%q) = %q, %v, want %v", tt.name, path, err, tt.want)
+		}
+	}
+
+	_, err := ServerFileInput{Name: "2024/voice.mp3"}.path(&AudioService{})
+	if !errors.Is(err, ErrImportDisabled) {
+		t.Errorf("未配置导入目录: %v, want %v", err, ErrImportDisabled)
+	}
+}
+
+func TestUploadInputStaysInUploadDir(t *testing.T) {
+	root := t.TempDir()
+	s := &AudioService{UploadDir: filepath.Join(root, "uploads")}
+	inside := filepath.Join(s.UploadDir, "a.mp3")
+	outside := filepath.Join(root, "keep.mp3")
+	mustWrite(t, inside)
+	mustWrite(t, outside)
+
+	if err := s.ValidateInput(UploadInput{Path: inside}); err != nil {
+		t.Errorf("ValidateInput(上传目录内) = %v", err)
+	}
+	if err := s.ValidateInput(UploadInput{Path: outside}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("ValidateInput(上传目录外) = %v, want %v", err, ErrInvalidInput)
+	}
+	if err := s.ValidateInput(nil); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("ValidateInput(nil) = %v, want %v", err, ErrInvalidInput)
+	}
+
+	s.DiscardInput(UploadInput{Path: outside})
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("上传目录外的文件不应被删除: %v", err)
+	}
+	s.DiscardInput(UploadInput{Path: inside})
+	if _, err := os.Stat(inside); !os.IsNotExist(err) {
+		t.Errorf("上传文件应被删除: %v", err)
+	}
+}
+
+func TestRemoteURLInputValidate(t *testing.T) {
+	tests := map[string]bool{
+		"http://example.com/a.mp3":  true,
+		"https://example.com/a.mp3": true,
+		"ftp://example.com/a.mp3":   false,
+		"file:///etc/passwd":        false,
+		"example.com/a.mp3":         false,
+		"http://":                   false,
+		"://bad":                    false,
+	}
+	for raw, ok := range tests {
+		err := RemoteURLInput{URL: raw}.validate(nil)
+		if ok && err != nil {
+			t.Errorf("validate(%q) = %v, want nil", raw, err)
+		}
+		if !ok && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("validate(%q) = %v, want %v", raw, err, ErrInvalidInput)
+		}
+	}
+}
+
+// mustWrite 创建文件及其所在目录
+func mustWrite(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("ID3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustEval 解析路径中的符号链接，临时目录本身可能位于符号链接下（如macOS的/var）
+func mustEval(t *testing.T, path string) string {
+	t.Helper()
+	p, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"audio-converter/utils"
+)
+
+// JobStatus 任务状态
+type JobStatus string
+
+// 任务状态取值
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+var (
+	// ErrJobQueueFull 任务队列已满
+	ErrJobQueueFull = errors.New("任务队列已满")
+	// ErrJobNotFound 任务不存在
+	ErrJobNotFound = errors.New("任务不存在")
+	// ErrJobFinished 任务已结束，无法取消
+	ErrJobFinished = errors.New("任务已结束")
+)
+
+// Job 异步转换任务
+type Job struct {
+	ID            string     `json:"id"`
+	Status        JobStatus  `json:"status"`
+	Filename      string     `json:"filename,omitempty"`
+	Name          string     `json:"name,omitempty"`           // 下载时展示的文件名
+	Size          int64      `json:"size,omitempty"`           // 输出文件大小（字节）
+	AudioDuration float64    `json:"audio_duration,omitempty"` // 输出音频时长（秒）
+	Error         string     `json:"error,omitempty"`
+	Progress      float64    `json:"progress"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+
+	// Parts 分段转换的各个分段，此时 Filename 指向清单文件
+	Parts []*ConvertResult `json:"parts,omitempty"`
+	// Filters 解码时应用的音频处理滤镜
+	Filters []string `json:"filters,omitempty"`
+
+	input    Input
+	opts     ConvertOptions
+	ctx      context.Context
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// Finished 判断任务是否已结束
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+// JobManager 基于有界工作池的异步任务管理器
+type JobManager struct {
+	service *AudioService
+	queue   chan *Job
+	ctx     context.Context
+	stop    context.CancelFunc
+	workers sync.WaitGroup
+
+	mu   sync.RWMutex
+	jobs map[string]*Job
+	subs map[string][]chan Job // 任务状态订阅者
+}
+
+// NewJobManager 创建任务管理器并启动workers个工作协程，队列最多容纳queueSize个等待任务
+func NewJobManager(service *AudioService, workers, queueSize int) *JobManager {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &JobManager{
+		service: service,
+		queue:   make(chan *Job, queueSize),
+		ctx:     ctx,
+		stop:    stop,
+		jobs:    make(map[string]*Job),
+		subs:    make(map[string][]chan Job),
+	}
+	m.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go m.worker(i)
+	}
+	utils.Info("任务管理器初始化: 工作协程=%d, 队列长度=%d", workers, queueSize)
+	return m
+}
+
+// Submit 提交转换任务，立即返回任务快照
+// 任务的生命周期与提交请求无关，只能通过 Cancel 或 Shutdown 终止
+func (m *JobManager) Submit(input Input, opts ConvertOptions) (Job, error) {
+	if err := m.service.ValidateInput(input); err != nil {
+		return Job{}, err
+	}
+	if err := m.service.ValidateConvertOptions(opts); err != nil {
+		return Job{}, err
+	}
+	if err := m.service.CheckInput(input); err != nil {
+		return Job{}, err
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	job := &Job{
+		ID:        newRandomID(),
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+		input:     input,
+		opts:      opts,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	m.mu.Lock()
+	select {
+	case m.queue <- job:
+		m.jobs[job.ID] = job
+	default:
+		m.mu.Unlock()
+		cancel()
+		return Job{}, ErrJobQueueFull
+	}
+	// 入队后工作协程可能立即开始执行，需在解锁前复制快照
+	snapshot := *job
+	m.mu.Unlock()
+
+	utils.Info("任务已提交: %s", job.ID)
+	return snapshot, nil
+}
+
+// Get 获取任务快照
+func (m *JobManager) Get(id string) (Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// Cancel 取消任务；排队中的任务不会再执行，执行中的任务会终止其外部进程
+func (m *JobManager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	if job.Finished() {
+		return *job, ErrJobFinished
+	}
+
+	job.canceled = true
+	job.cancel()
+	if job.Status == JobQueued {
+		job.Status = JobCanceled
+		job.FinishedAt = timePtr(time.Now())
+		m.notify(job)
+	}
+	utils.Info("任务已取消: %s", id)
+	return *job, nil
+}
+
+// Subscribe 订阅任务状态变化，返回的通道总是保存最新的任务快照，
+// 订阅时会立即收到当前状态；使用完毕后必须调用返回的取消函数
+func (m *JobManager) Subscribe(id string) (<-chan Job, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, ErrJobNotFound
+	}
+
+	ch := make(chan Job, 1)
+	ch <- *job
+	m.subs[id] = append(m.subs[id], ch)
+
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		subs := m.subs[id]
+		for i, c := range subs {
+			if c == ch {
+				m.subs[id] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(m.subs[id]) == 0 {
+			delete(m.subs, id)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// notify 向订阅者推送任务快照，调用方需持有写锁
+// 订阅者来不及读取时丢弃旧快照，只保留最新状态
+func (m *JobManager) notify(job *Job) {
+	for _, ch := range m.subs[job.ID] {
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- *job:
+		default:
+		}
+	}
+}
+
+// setProgress 更新任务进度
+func (m *JobManager) setProgress(job *Job, percent float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job.Finished() || percent <= job.Progress {
+		return
+	}
+	job.Progress = percent
+	m.notify(job)
+}
+
+// Shutdown 取消所有未结束的任务，并最多等待timeout让工作协程退出，
+// 返回false表示超时时仍有任务未退出，此时不应清理临时文件
+func (m *JobManager) Shutdown(timeout time.Duration) bool {
+	m.stop()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		utils.Warn("等待任务退出超时(%s)", timeout)
+		return false
+	}
+
+	// 工作协程已全部退出，清理仍在排队的任务
+	for {
+		select {
+		case job := <-m.queue:
+			m.mu.Lock()
+			m.cancelQueued(job)
+			m.mu.Unlock()
+			m.discardInput(job)
+		default:
+			return true
+		}
+	}
+}
+
+// Prune 删除结束时间早于maxAge的任务记录
+func (m *JobManager) Prune(maxAge time.Duration) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	count := 0
+	for id, job := range m.jobs {
+		if job.Finished() && job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+			count++
+		}
+	}
+	return count
+}
+
+// worker 从队列中取出任务并执行，Shutdown 后退出
+func (m *JobManager) worker(index int) {
+	defer m.workers.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case job := <-m.queue:
+			m.run(index, job)
+		}
+	}
+}
+
+// run 执行一个任务并记录结果
+func (m *JobManager) run(index int, job *Job) {
+	m.mu.Lock()
+	if job.canceled || m.ctx.Err() != nil {
+		// 服务关闭时工作协程可能仍会取出排队的任务，不再开始执行
+		m.cancelQueued(job)
+		m.mu.Unlock()
+		m.discardInput(job)
+		return
+	}
+	job.Status = JobRunning
+	job.StartedAt = timePtr(time.Now())
+	job.opts.Progress = func(percent float64) {
+		m.setProgress(job, percent)
+	}
+	m.notify(job)
+	m.mu.Unlock()
+
+	utils.Debug("工作协程%d开始执行任务: %s", index, job.ID)
+	// 任务队列本身有界，这里阻塞等待转换槽位而不是直接拒绝
+	var result *ConvertResult
+	var err error
+	if m.service.Limiter != nil {
+		err = m.service.Limiter.Wait(job.ctx)
+	}
+	if err == nil {
+		result, err = m.service.convert(job.ctx, job.input, job.opts)
+		m.service.release()
+	} else {
+		m.discardInput(job)
+	}
+	job.cancel()
+
+	m.mu.Lock()
+	job.FinishedAt = timePtr(time.Now())
+	switch {
+	case job.canceled || err != nil && m.ctx.Err() != nil:
+		// 被取消或因服务关闭而中断；输出可能来自缓存并被其他请求共享，交由定期清理处理
+		job.Status = JobCanceled
+	case err != nil:
+		job.Status = JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = JobSucceeded
+		job.Filename = result.Filename
+		job.Name = result.Name
+		job.AudioDuration = result.AudioDuration
+		job.Size = result.Size
+		job.Parts = result.Parts
+		job.Filters = result.Filters
+		job.Progress = 100
+	}
+	job.input = nil
+	job.opts.Progress = nil
+	m.notify(job)
+	status := job.Status
+	m.mu.Unlock()
+
+	if err != nil {
+		utils.Error("任务执行失败: %s: %v", job.ID, err)
+	} else {
+		utils.Info("任务执行结束: %s, 状态: %s", job.ID, status)
+	}
+}
+
+// cancelQueued 将未开始执行的任务标记为已取消，调用方需持有m.mu
+func (m *JobManager) cancelQueued(job *Job) {
+	job.cancel()
+	if job.Status == JobCanceled {
+		return
+	}
+	job.canceled = true
+	job.Status = JobCanceled
+	job.FinishedAt = timePtr(time.Now())
+	m.notify(job)
+}
+
+// discardInput 清理已取消任务的上传文件
+func (m *JobManager) discardInput(job *Job) {
+	m.service.DiscardInput(job.input)
+	m.mu.Lock()
+	job.input = nil
+	m.mu.Unlock()
+}
+
+// newRandomID 生成不可猜测的随机ID，用于任务ID和输出文件名
+func newRandomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("20060102150405.000000000")))
+	}
+	return hex.EncodeToString(b)
+}
+
+// timePtr 返回时间的指针，用于JSON中省略未设置的时间
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newBlockedJobService 返回转换槽位已被占满的服务，任务会一直等待槽位直到被取消
+func newBlockedJobService(t *testing.T) *AudioService {
+	t.Helper()
+	registry := NewRegistry()
+	registry.RegisterDecoder(&WAVDecoder{})
+	registry.RegisterEncoder(&SilkEncoder{})
+	s := &AudioService{
+		UploadDir: t.TempDir(),
+		SilkDir:   t.TempDir(),
+		Registry:  registry,
+		Limiter:   NewLimiter(1, 0),
+	}
+	if err := s.Limiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// submitUpload 在上传目录中创建输入文件并提交任务
+func submitUpload(t *testing.T, m *JobManager, s *AudioService, name string) (Job, string, error) {
+	t.Helper()
+	path := filepath.Join(s.UploadDir, name)
+	mustWrite(t, path)
+	job, err := m.Submit(UploadInput{Path: path}, ConvertOptions{Decoder: "wav"})
+	return job, path, err
+}
+
+// waitStatus 等待任务进入指定状态
+func waitStatus(t *testing.T, m *JobManager, id string, status JobStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, err := m.Get(id); err == nil && job.Status == status {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(id)
+	t.Fatalf("任务 %s 状态 = %s, want %s", id, job.Status, status)
+}
+
+func TestJobManagerShutdown(t *testing.T) {
+	s := newBlockedJobService(t)
+	m := NewJobManager(s, 1, 4)
+
+	running, runningPath, err := submitUpload(t, m, s, "running.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, running.ID, JobRunning)
+	queued, queuedPath, err := submitUpload(t, m, s, "queued.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.Shutdown(5 * time.Second) {
+		t.Fatal("Shutdown 超时，工作协程未退出")
+	}
+	// Shutdown 返回时工作协程已退出，执行中和排队的任务都已结束，上传文件已删除
+	for _, id := range []string{running.ID, queued.ID} {
+		if job, _ := m.Get(id); !job.Finished() {
+			t.Errorf("任务 %s 状态 = %s, 应已结束", id, job.Status)
+		}
+	}
+	if job, _ := m.Get(queued.ID); job.Status != JobCanceled {
+		t.Errorf("排队任务状态 = %s, want %s", job.Status, JobCanceled)
+	}
+	for _, path := range []string{runningPath, queuedPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("上传文件 %s 应已删除: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestJobManagerQueueAndCancel(t *testing.T) {
+	s := newBlockedJobService(t)
+	m := NewJobManager(s, 1, 1)
+	defer m.Shutdown(5 * time.Second)
+
+	running, _, err := submitUpload(t, m, s, "running.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, running.ID, JobRunning)
+	queued, queuedPath, err := submitUpload(t, m, s, "queued.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := submitUpload(t, m, s, "overflow.mp3"); !errors.Is(err, ErrJobQueueFull) {
+		t.Errorf("队列已满时 Submit = %v, want %v", err, ErrJobQueueFull)
+	}
+
+	job, err := m.Cancel(queued.ID)
+	if err != nil || job.Status != JobCanceled {
+		t.Errorf("Cancel(排队任务) = %s, %v", job.Status, err)
+	}
+	if _, err := m.Cancel(queued.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("重复取消 = %v, want %v", err, ErrJobFinished)
+	}
+
+	// 执行中的任务取消后由工作协程结束，随后取出已取消的排队任务并删除其上传文件
+	if _, err := m.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, running.ID, JobCanceled)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(queuedPath); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("已取消的排队任务的上传文件未删除")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := m.Get("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get(不存在) = %v, want %v", err, ErrJobNotFound)
+	}
+	if _, err := m.Cancel("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Cancel(不存在) = %v, want %v", err, ErrJobNotFound)
+	}
+}
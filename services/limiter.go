@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrServerBusy 并发转换已满且等待队列已满
+var ErrServerBusy = errors.New("服务繁忙，请稍后重试")
+
+// Limiter 限制同时运行的转换数量，并为等待者提供有界队列
+type Limiter struct {
+	slots   chan struct{}
+	maxWait int
+
+	mu      sync.Mutex
+	waiting int
+}
+
+// NewLimiter 创建限流器：最多workers个转换同时运行，最多maxWait个请求排队等待
+func NewLimiter(workers, maxWait int) *Limiter {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxWait < 0 {
+		maxWait = 0
+	}
+	return &Limiter{
+		slots:   make(chan struct{}, workers),
+		maxWait: maxWait,
+	}
+}
+
+// Acquire 获取一个运行槽位；等待队列已满时立即返回 ErrServerBusy，
+// 排队期间ctx被取消时返回ctx的错误
+func (l *Limiter) Acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.mu.Lock()
+	if l.waiting >= l.maxWait {
+		l.mu.Unlock()
+		return ErrServerBusy
+	}
+	l.waiting++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+
+	return l.Wait(ctx)
+}
+
+// Wait 阻塞直到获取运行槽位，不受等待队列长度限制（供已有独立队列的异步任务使用）
+func (l *Limiter) Wait(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release 释放运行槽位
+func (l *Limiter) Release() {
+	<-l.slots
+}
+
+// Stats 返回当前运行中和等待中的转换数量
+func (l *Limiter) Stats() (running, waiting int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.slots), l.waiting
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(1, 1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 槽位已满，第二个请求排队等待
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.Acquire(context.Background()) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, waiting := l.Stats(); waiting == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("第二个请求未进入等待队列")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 等待队列已满，第三个请求立即被拒绝
+	if err := l.Acquire(context.Background()); !errors.Is(err, ErrServerBusy) {
+		t.Errorf("队列已满时 Acquire = %v, want %v", err, ErrServerBusy)
+	}
+	// Wait 不受等待队列长度限制，只受ctx控制
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	l.Release()
+	if err := <-acquired; err != nil {
+		t.Errorf("释放槽位后等待者 Acquire = %v", err)
+	}
+	if running, waiting := l.Stats(); running != 1 || waiting != 0 {
+		t.Errorf("Stats = %d, %d, want 1, 0", running, waiting)
+	}
+}
+
+func TestLimiterCanceledWhileWaiting(t *testing.T) {
+	l := NewLimiter(1, 4)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire(已取消) = %v, want %v", err, context.Canceled)
+	}
+	if _, waiting := l.Stats(); waiting != 0 {
+		t.Errorf("取消后等待数量 = %d, want 0", waiting)
+	}
+}
+
+func TestNewLimiterBounds(t *testing.T) {
+	l := NewLimiter(0, -1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("至少应有一个槽位: %v", err)
+	}
+	if err := l.Acquire(context.Background()); !errors.Is(err, ErrServerBusy) {
+		t.Errorf("maxWait为负数时 Acquire = %v, want %v", err, ErrServerBusy)
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"audio-converter/utils"
+)
+
+// metaSuffix 输出文件元数据（隐藏的旁路文件）的后缀
+const metaSuffix = ".meta.json"
+
+// 下载文件名的最大长度（字节）
+const maxNameLength = 200
+
+// NamingOptions 输出文件命名选项
+type NamingOptions struct {
+	// NameTemplate 下载文件名模板，支持 {original} {date} {time} {id} {ext}，
+	// 例如 "{original}_{date}.silk"；为空时使用原始文件名
+	NameTemplate string `json:"name_template" form:"name_template"`
+	// OriginalName 原始上传文件名或URL中的文件名，由接口层填写
+	OriginalName string `json:"-" form:"-"`
+}
+
+// ConvertResult 转换结果
+type ConvertResult struct {
+	Filename string `json:"filename"`           // 存储文件名（随机ID，用于下载URL）
+	Name     string `json:"name"`               // 下载时展示的文件名
+	Original string `json:"original,omitempty"` // 原始文件名
+	Cached   bool   `json:"cached"`             // 是否命中转换缓存
+
+	AudioDuration float64 `json:"audio_duration,omitempty"` // 输出音频时长（秒），读取失败时为0
+	Size          int64   `json:"size"`                     // 输出文件大小（字节）
+
+	// Parts 分段转换的各个分段，此时 Filename 指向清单文件，AudioDuration 和 Size 为各分段之和
+	Parts []*ConvertResult `json:"parts,omitempty"`
+	// Filters 解码时按顺序应用的音频处理滤镜，未启用处理时为空
+	Filters []string `json:"filters,omitempty"`
+}
+
+// OutputMeta 输出文件元数据
+type OutputMeta struct {
+	Filename  string    `json:"filename"`
+	Name      string    `json:"name"`
+	Original  string    `json:"original,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OriginalNameFromURL 从URL路径中提取文件名，忽略查询参数
+func OriginalNameFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// renderName 根据模板生成下载文件名
+func renderName(opts NamingOptions, id, ext string, now time.Time) string {
+	original := strings.TrimSuffix(opts.OriginalName, filepath.Ext(opts.OriginalName))
+	if original == "" {
+		original = id
+	}
+
+	tmpl := opts.NameTemplate
+	if tmpl == "" {
+		tmpl = "{original}.{ext}"
+	}
+	name := strings.NewReplacer(
+		"{original}", original,
+		"{date}", now.Format("20060102"),
+		"{time}", now.Format("150405"),
+		"{id}", id,
+		"{ext}", ext,
+	).Replace(tmpl)
+
+	name = sanitizeName(name)
+	if name == "" {
+		name = id
+	}
+	if !strings.EqualFold(filepath.Ext(name), "."+ext) {
+		name += "." + ext
+	}
+	return name
+}
+
+// sanitizeName 替换路径分隔符、引号和HTML特殊字符，去除控制字符，并限制长度
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case strings.ContainsRune(`/\"'<>&`, r):
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	for len(name) > maxNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// MetaPath 返回输出文件对应的元数据文件路径
+func MetaPath(dir, filename string) string {
+	return filepath.Join(dir, "."+filename+metaSuffix)
+}
+
+// IsMetaFile 判断文件名是否为元数据文件，并返回对应的输出文件名
+func IsMetaFile(name string) (string, bool) {
+	if !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, metaSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(name, "."), metaSuffix), true
+}
+
+// ReadMeta 读取输出文件的元数据
+func (s *AudioService) ReadMeta(filename string) (OutputMeta, error) {
+	var meta OutputMeta
+	data, err := os.ReadFile(MetaPath(s.SilkDir, filename))
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// writeMeta 写入输出文件的元数据
+func (s *AudioService) writeMeta(meta OutputMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetaPath(s.SilkDir, meta.Filename), data, 0644)
+}
+
+// newOutput 为一次转换分配存储文件名和下载文件名
+func newOutput(ext string, opts NamingOptions) (filename, name string) {
+	id := newRandomID()
+	return id + "." + ext, renderName(opts, id, ext, time.Now())
+}
+
+// publishResult 记录元数据并生成包含输出时长和大小的转换结果
+func (s *AudioService) publishResult(ctx context.Context, filename, name string, opts NamingOptions, cached bool) *ConvertResult {
+	meta := OutputMeta{
+		Filename:  filename,
+		Name:      name,
+		Original:  opts.OriginalName,
+		CreatedAt: time.Now(),
+	}
+	if err := s.writeMeta(meta); err != nil {
+		// 元数据只影响下载文件名，写入失败不影响转换结果
+		utils.Warn("写入文件元数据失败: %s: %v", filename, err)
+	}
+	result := &ConvertResult{
+		Filename: filename,
+		Name:     name,
+		Original: opts.OriginalName,
+		Cached:   cached,
+	}
+	outputPath := filepath.Join(s.SilkDir, filename)
+	if info, err := s.ProbeFile(ctx, outputPath); err == nil {
+		result.AudioDuration = info.Duration
+		result.Size = info.Size
+	} else {
+		// 时长只用于展示，读取失败不影响转换结果
+		utils.Warn("读取输出音频信息失败: %s: %v", filename, err)
+		if stat, err := os.Stat(outputPath); err == nil {
+			result.Size = stat.Size()
+		}
+	}
+	return result
+}
+
+// reuseCached 为缓存命中的输出创建新的存储文件（优先硬链接），
+// 使每次请求都获得独立的下载ID和元数据
+func (s *AudioService) reuseCached(ctx context.Context, cached string, opts NamingOptions) (*ConvertResult, error) {
+	ext := strings.TrimPrefix(filepath.Ext(cached), ".")
+	filename, name := newOutput(ext, opts)
+	src := filepath.Join(s.SilkDir, cached)
+	dst := filepath.Join(s.SilkDir, filename)
+
+	if err := os.Link(src, dst); err != nil {
+		if err := copyFile(src, dst); err != nil {
+			return nil, fmt.Errorf("复制缓存文件失败: %v", err)
+		}
+	}
+	return s.publishResult(ctx, filename, name, opts, true), nil
+}
+
+// copyFile 复制文件，目标先写入临时路径再重命名
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := tempOutputPath(dst)
+	if err := writeFile(tmp, in); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"voice.silk", "voice.silk"},
+		{"语音 01.silk", "语音 01.silk"},
+		{"../../etc/passwd", "_.._etc_passwd"},
+		{`a\b/c.mp3`, "a_b_c.mp3"},
+		{`<img src=x onerror=alert(1)>.mp3`, "_img src=x onerror=alert(1)_.mp3"},
+		{`Tom & "Jerry's".mp3`, "Tom _ _Jerry_s_.mp3"},
+		{"a\x00b\r\nc\t.mp3", "abc.mp3"},
+		{"  ..hidden..  ", "hidden"},
+		{"...", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeName(tt.in); got != tt.want {
+			t.Errorf("sanitizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	long := sanitizeName(strings.Repeat("语", 100))
+	if len(long) > maxNameLength {
+		t.Errorf("sanitizeName 长度 = %d, 超过上限 %d", len(long), maxNameLength)
+	}
+	if !strings.HasPrefix(strings.Repeat("语", 100), long) {
+		t.Errorf("sanitizeName 截断时切开了多字节字符: %q", long)
+	}
+}
+
+func TestRenderName(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		opts NamingOptions
+		want string
+	}{
+		{NamingOptions{}, "abc123.silk"},
+		{NamingOptions{OriginalName: "会议录音.mp3"}, "会议录音.silk"},
+		{NamingOptions{OriginalName: "voice.mp3", NameTemplate: "{original}_{date}_{time}.{ext}"}, "voice_20240506_070809.silk"},
+		{NamingOptions{NameTemplate: "{id}"}, "abc123.silk"},
+		{NamingOptions{NameTemplate: "out.SILK"}, "out.SILK"},
+		{NamingOptions{NameTemplate: "../{original}.{ext}", OriginalName: "a.mp3"}, "_a.silk"},
+		{NamingOptions{NameTemplate: "<b>{original}</b>", OriginalName: "a.mp3"}, "_b_a__b_.silk"},
+		{NamingOptions{NameTemplate: "..."}, "abc123.silk"},
+	}
+	for _, tt := range tests {
+		if got := renderName(tt.opts, "abc123", "silk", now); got != tt.want {
+			t.Errorf("renderName(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestOriginalNameFromURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/media/voice.mp3?token=x": "voice.mp3",
+		"https://example.com/a%20b.wav":               "a b.wav",
+		"https://example.com/":                        "",
+		"https://example.com":                         "",
+		"://bad":                                      "",
+	}
+	for raw, want := range tests {
+		if got := OriginalNameFromURL(raw); got != want {
+			t.Errorf("OriginalNameFromURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestIsMetaFile(t *testing.T) {
+	name, ok := IsMetaFile(".abc.silk" + metaSuffix)
+	if !ok || name != "abc.silk" {
+		t.Errorf("IsMetaFile = %q, %v", name, ok)
+	}
+	if _, ok := IsMetaFile("abc.silk"); ok {
+		t.Error("普通输出文件不应被识别为元数据文件")
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultSilkEncoder 默认的SILK编码器
+const defaultSilkEncoder = "encoder"
+
+// OutputSILK 默认输出格式，由外部SILK编码器编码
+const OutputSILK = "silk"
+
+// OutputFormat 通过ffmpeg编码的输出格式
+type OutputFormat struct {
+	Name       string   // 请求中的 format 参数
+	Ext        string   // 输出文件扩展名（不含点）
+	SampleRate int      // 格式要求的采样率，0表示沿用PCM采样率
+	Args       []string // ffmpeg编码参数，输出写入临时文件，需显式指定封装格式
+}
+
+// outputFormats SILK之外的输出格式，转换（format参数）与SILK解码（/decode）共用，同一名称只对应一种编码
+var outputFormats = []OutputFormat{
+	{Name: "amr", Ext: "amr", SampleRate: 8000, Args: []string{"-acodec", "libopencore_amrnb", "-b:a", "12.2k", "-f", "amr"}},
+	{Name: "amr-wb", Ext: "awb", SampleRate: 16000, Args: []string{"-acodec", "libvo_amrwbenc", "-b:a", "23.85k", "-f", "amr"}},
+	{Name: "opus", Ext: "opus", Args: []string{"-acodec", "libopus", "-b:a", "32k", "-application", "voip", "-f", "ogg"}},
+	{Name: "ogg", Ext: "ogg", Args: []string{"-acodec", "libvorbis", "-q:a", "4", "-f", "ogg"}},
+	{Name: "mp3", Ext: "mp3", Args: []string{"-acodec", "libmp3lame", "-b:a", "128k", "-f", "mp3"}},
+	{Name: "wav", Ext: "wav", Args: []string{"-acodec", "pcm_s16le", "-f", "wav"}},
+}
+
+// findOutputFormat 按名称查找SILK之外的输出格式
+func findOutputFormat(name string) (OutputFormat, bool) {
+	for _, f := range outputFormats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return OutputFormat{}, false
+}
+
+// OutputFormatNames 返回支持的输出格式，第一个为默认格式
+func OutputFormatNames() []string {
+	names := []string{OutputSILK}
+	for _, f := range outputFormats {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// FFmpegEncoder 使用ffmpeg将PCM编码为AMR、Opus、MP3等格式，编码器名称即格式名称
+type FFmpegEncoder struct {
+	Path   string
+	Format OutputFormat
+}
+
+// Name 返回编码器名称
+func (e *FFmpegEncoder) Name() string { return e.Format.Name }
+
+// Ext 返回输出文件扩展名
+func (e *FFmpegEncoder) Ext() string { return e.Format.Ext }
+
+// Encode 从stdin读取PCM，按格式要求重采样后编码
+func (e *FFmpegEncoder) Encode(ctx context.Context, r io.Reader, outputPath string, format PCMFormat) error {
+	args := []string{"-y",
+		"-f", "s16le",
+		"-ar", strconv.Itoa(format.SampleRate),
+		"-ac", strconv.Itoa(format.Channels),
+		"-i", "pipe:0"}
+	if e.Format.SampleRate != 0 {
+		args = append(args, "-ar", strconv.Itoa(e.Format.SampleRate), "-ac", "1")
+	}
+	args = append(args, e.Format.Args...)
+	args = append(args, outputPath)
+	cmd := exec.CommandContext(ctx, e.Path, args...)
+	cmd.Stdin = r
+	return runCommand("FFmpeg", cmd)
+}
+
+// encoderName 按输出格式选择编码器：SILK使用请求指定或默认的SILK编码器，其他格式使用同名的ffmpeg编码器
+// 编码器与输出格式共用名称，SILK输出时只能指定SILK编码器，避免 encoder=mp3 悄悄输出MP3
+func (s *AudioService) encoderName(opts ConvertOptions) (string, error) {
+	format := strings.ToLower(opts.Format)
+	if format == "" || format == OutputSILK {
+		if opts.Encoder == "" {
+			return defaultSilkEncoder, nil
+		}
+		if e, err := s.Registry.Encoder(opts.Encoder); err == nil && e.Ext() != OutputSILK {
+			return "", fmt.Errorf("%w: %s 不是SILK编码器，输出其他格式请使用 format 参数", ErrInvalidOptions, opts.Encoder)
+		}
+		return opts.Encoder, nil
+	}
+	if opts.Encoder != "" && opts.Encoder != format {
+		return "", fmt.Errorf("%w: encoder 只能用于SILK输出，format为%s时不能指定", ErrInvalidOptions, format)
+	}
+	if _, err := s.Registry.Encoder(format); err != nil {
+		return "", fmt.Errorf("%w: 不支持的输出格式: %s", ErrInvalidOptions, opts.Format)
+	}
+	return format, nil
+}
+
+// contentTypes 下载文件的Content-Type，按扩展名查找
+var contentTypes = map[string]string{
+	".silk": FormatSILK.MIME,
+	".amr":  FormatAMR.MIME,
+	".awb":  FormatAWB.MIME,
+	".opus": FormatOpus.MIME,
+	".ogg":  FormatOgg.MIME,
+	".mp3":  FormatMP3.MIME,
+	".wav":  FormatWAV.MIME,
+	".json": "application/json",
+}
+
+// ContentType 返回输出文件的Content-Type，未知扩展名返回 application/octet-stream
+func ContentType(filename string) string {
+	if t, ok := contentTypes[strings.ToLower(filepath.Ext(filename))]; ok {
+		return t
+	}
+	return "application/octet-stream"
+}
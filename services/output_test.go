@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncoderName(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterEncoder(&SilkEncoder{})
+	for _, f := range outputFormats {
+		registry.RegisterEncoder(&FFmpegEncoder{Format: f})
+	}
+	s := &AudioService{Registry: registry}
+
+	tests := []struct {
+		opts ConvertOptions
+		want string
+		err  bool
+	}{
+		{ConvertOptions{}, defaultSilkEncoder, false},
+		{ConvertOptions{Format: "SILK"}, defaultSilkEncoder, false},
+		{ConvertOptions{Encoder: "encoder"}, "encoder", false},
+		{ConvertOptions{Format: "mp3"}, "mp3", false},
+		{ConvertOptions{Format: "AMR-WB"}, "amr-wb", false},
+		{ConvertOptions{Format: "ogg", Encoder: "ogg"}, "ogg", false},
+		// SILK输出时不能借encoder参数输出其他格式
+		{ConvertOptions{Encoder: "mp3"}, "", true},
+		{ConvertOptions{Format: "silk", Encoder: "wav"}, "", true},
+		{ConvertOptions{Format: "mp3", Encoder: "encoder"}, "", true},
+		{ConvertOptions{Format: "flac"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := s.encoderName(tt.opts)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("encoderName(%+v) = %q, %v, want %v", tt.opts, got, err, ErrInvalidOptions)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("encoderName(%+v) = %q, %v, want %q", tt.opts, got, err, tt.want)
+		}
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	names := OutputFormatNames()
+	if names[0] != OutputSILK {
+		t.Errorf("默认输出格式 = %s, want %s", names[0], OutputSILK)
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			t.Errorf("输出格式 %s 重复", name)
+		}
+		seen[name] = true
+	}
+	for _, f := range outputFormats {
+		if got, ok := findOutputFormat(f.Name); !ok || got.Ext != f.Ext {
+			t.Errorf("findOutputFormat(%s) = %+v, %v", f.Name, got, ok)
+		}
+		// 每种输出格式下载时都应有对应的Content-Type
+		if ContentType("a."+f.Ext) == "application/octet-stream" {
+			t.Errorf("%s 格式缺少Content-Type", f.Name)
+		}
+	}
+	if _, ok := findOutputFormat(OutputSILK); ok {
+		t.Error("SILK不应在ffmpeg输出格式中")
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := map[string]string{
+		"a.silk":        "audio/silk",
+		"a.MP3":         "audio/mpeg",
+		"a.ogg":         "audio/ogg",
+		"manifest.json": "application/json",
+		"a.bin":         "application/octet-stream",
+		"noext":         "application/octet-stream",
+	}
+	for name, want := range tests {
+		if got := ContentType(name); got != want {
+			t.Errorf("ContentType(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// PCMFormat 解码器与编码器之间传递的PCM格式（16位有符号小端）
+type PCMFormat struct {
+	SampleRate int
+	Channels   int
+}
+
+// 默认中间PCM格式：24kHz单声道
+var defaultPCMFormat = PCMFormat{SampleRate: 24000, Channels: 1}
+
+// Decoder 将输入音频解码为PCM
+type Decoder interface {
+	// Name 返回解码器名称，用于注册和按请求选择
+	Name() string
+	// Decode 将inputPath解码为指定格式的PCM并流式写入w，ctx取消时应尽快退出
+	Decode(ctx context.Context, inputPath string, format PCMFormat, w io.Writer) error
+}
+
+// Encoder 将PCM编码为目标格式
+type Encoder interface {
+	// Name 返回编码器名称，用于注册和按请求选择
+	Name() string
+	// Ext 返回输出文件扩展名（不含点）
+	Ext() string
+	// Encode 从r读取PCM流，编码后写入outputPath，ctx取消时应尽快退出
+	Encode(ctx context.Context, r io.Reader, outputPath string, format PCMFormat) error
+}
+
+// Registry 解码器、编码器与TTS引擎注册表
+type Registry struct {
+	mu       sync.RWMutex
+	decoders map[string]Decoder
+	encoders map[string]Encoder
+	tts      map[string]TTSProvider
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		decoders: make(map[string]Decoder),
+		encoders: make(map[string]Encoder),
+		tts:      make(map[string]TTSProvider),
+	}
+}
+
+// RegisterDecoder 注册解码器，同名解码器会被覆盖
+func (r *Registry) RegisterDecoder(d Decoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[d.Name()] = d
+}
+
+// RegisterEncoder 注册编码器，同名编码器会被覆盖
+func (r *Registry) RegisterEncoder(e Encoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.encoders[e.Name()] = e
+}
+
+// RegisterTTS 注册TTS引擎，同名引擎会被覆盖
+func (r *Registry) RegisterTTS(p TTSProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tts[p.Name()] = p
+}
+
+// Decoder 按名称获取解码器
+func (r *Registry) Decoder(name string) (Decoder, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.decoders[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的解码器: %s", name)
+	}
+	return d, nil
+}
+
+// Encoder 按名称获取编码器
+func (r *Registry) Encoder(name string) (Encoder, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.encoders[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的编码器: %s", name)
+	}
+	return e, nil
+}
+
+// DecoderNames 返回已注册的解码器名称
+func (r *Registry) DecoderNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.decoders))
+	for name := range r.decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EncoderNames 返回已注册的编码器名称
+func (r *Registry) EncoderNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.encoders))
+	for name := range r.encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TTS 按名称获取TTS引擎
+func (r *Registry) TTS(name string) (TTSProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.tts[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的TTS引擎: %s", name)
+	}
+	return p, nil
+}
+
+// TTSNames 返回已注册的TTS引擎名称
+func (r *Registry) TTSNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tts))
+	for name := range r.tts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"audio-converter/services/silk"
+	"audio-converter/utils"
+)
+
+// ErrProbeTimeout 读取音频元数据超时
+var ErrProbeTimeout = errors.New("读取音频信息超时")
+
+// AudioInfo 音频元数据
+type AudioInfo struct {
+	Format     string  `json:"format"`                // 文件头识别出的格式，如 mp3
+	Codec      string  `json:"codec,omitempty"`       // 编码，如 mp3、pcm_s16le、silk
+	Duration   float64 `json:"duration"`              // 时长（秒）
+	SampleRate int     `json:"sample_rate,omitempty"` // 采样率，SILK文件中没有记录时为0
+	Channels   int     `json:"channels,omitempty"`
+	BitRate    int64   `json:"bit_rate,omitempty"` // 比特率（bit/s）
+	Size       int64   `json:"size"`               // 文件大小（字节）
+
+	Video   bool          `json:"video,omitempty"`   // 是否包含视频流
+	Streams []AudioStream `json:"streams,omitempty"` // 通过ffprobe读取时列出全部音频流，上述字段描述其中最佳的一条
+}
+
+// findFFprobe 查找ffprobe：优先使用ffmpeg同目录下的版本，其次在PATH中查找
+func findFFprobe(ffmpegPath string) string {
+	name := "ffprobe"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	sibling := filepath.Join(filepath.Dir(ffmpegPath), name)
+	if _, err := os.Stat(sibling); err == nil {
+		return sibling
+	}
+	if p, err := exec.LookPath("ffprobe"); err == nil {
+		utils.Info("在PATH中找到ffprobe: %s", p)
+		return p
+	}
+	return sibling
+}
+
+// Probe 读取输入的音频元数据，下载和ffprobe与转换共用转换槽位
+// UploadInput 类型的输入在返回时总会被删除
+func (s *AudioService) Probe(ctx context.Context, input Input) (*AudioInfo, error) {
+	defer s.DiscardInput(input)
+	if err := s.ValidateInput(input); err != nil {
+		return nil, err
+	}
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.release()
+	path, cleanup, err := s.resolveInput(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return s.ProbeFile(ctx, path)
+}
+
+// ProbeFile 读取本地文件的音频元数据
+// WAV和SILK直接解析文件，其他格式调用ffprobe（ffprobe不支持SILK）
+func (s *AudioService) ProbeFile(ctx context.Context, path string) (*AudioInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	header, err := readHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	format, err := checkAudio(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var info *AudioInfo
+	switch format {
+	case FormatSILK:
+		info, err = s.probeSILK(ctx, path)
+	case FormatWAV:
+		// 非PCM或损坏的WAV交给ffprobe处理
+		if info, err = probeWAV(path); err != nil {
+			utils.Debug("解析WAV文件头失败，改用ffprobe: %v", err)
+			info, err = s.ffprobe(ctx, path)
+		}
+	default:
+		info, err = s.ffprobe(ctx, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info.Format = format.Name
+	info.Size = stat.Size()
+	info.Duration = roundSeconds(info.Duration)
+	if info.BitRate == 0 && info.Duration > 0 {
+		info.BitRate = int64(float64(info.Size*8) / info.Duration)
+	}
+	return info, nil
+}
+
+// probeSILK 读取SILK时长
+// 每个数据包可包含20~100ms音频，容器中没有记录包时长，因此用外部decoder解码并按PCM字节数计算，
+// decoder不可用或解码失败时按每包20ms估算
+func (s *AudioService) probeSILK(ctx context.Context, path string) (*AudioInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	packets, err := silk.CountPackets(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("解析SILK文件失败: %v", err)
+	}
+	info := &AudioInfo{
+		Codec:    "silk",
+		Duration: float64(packets*silk.FrameDurationMs) / 1000,
+		Channels: 1,
+	}
+	if s.DecoderPath == "" {
+		return info, nil
+	}
+	n, err := s.silkPCMBytes(ctx, path)
+	if err != nil {
+		utils.Warn("解码SILK计算时长失败，按每包%dms估算: %v", silk.FrameDurationMs, err)
+		return info, nil
+	}
+	info.Duration = float64(n) / float64(silkPCMRate*2)
+	return info, nil
+}
+
+// silkPCMBytes 解码SILK文件，返回得到的PCM字节数
+func (s *AudioService) silkPCMBytes(ctx context.Context, path string) (int64, error) {
+	pcm := &countingWriter{w: io.Discard}
+	if err := s.decodeSILK(ctx, path, pcm); err != nil {
+		return 0, err
+	}
+	return pcm.n, nil
+}
+
+// probeWAV 根据fmt块和data块长度计算WAV时长
+func probeWAV(path string) (*AudioInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	hdr, dataSize, err := readWAVHeader(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	var codec string
+	switch {
+	case hdr.AudioFormat == 1 && hdr.BitsPerSample == 8:
+		codec = "pcm_u8"
+	case hdr.AudioFormat == 1:
+		codec = fmt.Sprintf("pcm_s%dle", hdr.BitsPerSample)
+	case hdr.AudioFormat == 3:
+		codec = fmt.Sprintf("pcm_f%dle", hdr.BitsPerSample)
+	default:
+		return nil, fmt.Errorf("不支持的WAV编码: 0x%04x", hdr.AudioFormat)
+	}
+	byteRate := int64(hdr.SampleRate) * int64(hdr.Channels) * int64(hdr.BitsPerSample) / 8
+	if byteRate == 0 {
+		return nil, fmt.Errorf("WAV fmt块无效")
+	}
+	// 流式写出的WAV可能没有回填data块长度
+	size := int64(dataSize)
+	if size == 0 || size > stat.Size() {
+		size = stat.Size()
+	}
+	return &AudioInfo{
+		Codec:      codec,
+		Duration:   float64(size) / float64(byteRate),
+		SampleRate: int(hdr.SampleRate),
+		Channels:   int(hdr.Channels),
+		BitRate:    byteRate * 8,
+	}, nil
+}
+
+// ffprobeOutput ffprobe -of json 的输出中需要的字段
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType   string `json:"codec_type"`
+		CodecName   string `json:"codec_name"`
+		SampleRate  string `json:"sample_rate"`
+		Channels    int    `json:"channels"`
+		BitRate     string `json:"bit_rate"`
+		Duration    string `json:"duration"`
+		Disposition struct {
+			Default     int `json:"default"`
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+		Tags struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// ffprobe 调用ffprobe读取全部音频流，返回最佳音频流的信息
+func (s *AudioService) ffprobe(ctx context.Context, path string) (*AudioInfo, error) {
+	probeCtx, cancel := stageContext(ctx, s.ProbeTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(probeCtx, s.FfprobePath,
+		"-v", "error",
+		"-show_entries", "stream=codec_type,codec_name,sample_rate,channels,bit_rate,duration:stream_disposition=default,attached_pic:"+
+			"stream_tags=language,title:format=duration,bit_rate",
+		"-of", "json",
+		path)
+	cmd.Stdout = &stdout
+	err := runCommand("FFprobe", cmd)
+	if err := stageError(ctx, probeCtx, err, ErrProbeTimeout); err != nil {
+		return nil, fmt.Errorf("读取音频信息失败: %w", err)
+	}
+
+	var out ffprobeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("解析ffprobe输出失败: %v", err)
+	}
+	info := &AudioInfo{}
+	var durations []string
+	for _, stream := range out.Streams {
+		switch stream.CodecType {
+		case "audio":
+			as := AudioStream{
+				Index:    len(info.Streams),
+				Codec:    stream.CodecName,
+				Channels: stream.Channels,
+				BitRate:  int64(parseFloatField(stream.BitRate)),
+				Language: stream.Tags.Language,
+				Title:    stream.Tags.Title,
+				Default:  stream.Disposition.Default == 1,
+			}
+			as.SampleRate, _ = strconv.Atoi(stream.SampleRate)
+			info.Streams = append(info.Streams, as)
+			durations = append(durations, stream.Duration)
+		case "video":
+			// MP3等文件中的封面图片也是视频流
+			if stream.Disposition.AttachedPic == 0 {
+				info.Video = true
+			}
+		}
+	}
+	if len(info.Streams) == 0 {
+		return nil, ErrNoAudioStream
+	}
+	best := bestAudioStream(info.Streams)
+	stream := info.Streams[best]
+	info.Codec = stream.Codec
+	info.Channels = stream.Channels
+	info.SampleRate = stream.SampleRate
+	// 流级别的字段在部分封装中缺失，缺失时使用容器级别的值
+	info.Duration = parseFloatField(durations[best], out.Format.Duration)
+	info.BitRate = stream.BitRate
+	if info.BitRate == 0 {
+		info.BitRate = int64(parseFloatField(out.Format.BitRate))
+	}
+	return info, nil
+}
+
+// parseFloatField 解析ffprobe输出的数值字段，依次尝试各个候选值，均无效时返回0
+func parseFloatField(values ...string) float64 {
+	for _, v := range values {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			return f
+		}
+	}
+	return 0
+}
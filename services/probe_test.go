@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// writeTestWAV 写出16位PCM WAV文件，dataSize为文件头中记录的data块长度
+func writeTestWAV(t *testing.T, path string, format PCMFormat, pcmBytes int, dataSize uint32) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeWAVHeader(&buf, format, dataSize); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(make([]byte, pcmBytes))
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProbeWAV(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "stereo.wav")
+	writeTestWAV(t, path, PCMFormat{SampleRate: 16000, Channels: 2}, 128000, 128000)
+	info, err := probeWAV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AudioInfo{Codec: "pcm_s16le", Duration: 2, SampleRate: 16000, Channels: 2, BitRate: 512000}
+	if !reflect.DeepEqual(*info, want) {
+		t.Errorf("probeWAV = %+v, want %+v", *info, want)
+	}
+
+	// 流式写出的WAV没有回填data块长度，按文件大小估算
+	path = filepath.Join(dir, "stream.wav")
+	writeTestWAV(t, path, PCMFormat{SampleRate: 8000, Channels: 1}, 15956, 0)
+	if info, err := probeWAV(path); err != nil || info.Duration != 1 {
+		t.Errorf("probeWAV(未回填长度) = %+v, %v, want 1秒", info, err)
+	}
+
+	// data块长度超出文件大小时同样按文件大小估算
+	path = filepath.Join(dir, "truncated.wav")
+	writeTestWAV(t, path, PCMFormat{SampleRate: 8000, Channels: 1}, 15956, 1<<30)
+	if info, err := probeWAV(path); err != nil || info.Duration != 1 {
+		t.Errorf("probeWAV(长度超出文件) = %+v, %v, want 1秒", info, err)
+	}
+}
+
+func TestProbeWAVInvalid(t *testing.T) {
+	dir := t.TempDir()
+	var hdr bytes.Buffer
+	writeWAVHeader(&hdr, PCMFormat{SampleRate: 16000, Channels: 1}, 0)
+
+	// MP3编码的WAV交给ffprobe处理
+	mp3 := append([]byte(nil), hdr.Bytes()...)
+	binary.LittleEndian.PutUint16(mp3[20:22], 0x55)
+	// 采样率为0时无法计算时长
+	zeroRate := append([]byte(nil), hdr.Bytes()...)
+	binary.LittleEndian.PutUint32(zeroRate[24:28], 0)
+
+	tests := map[string][]byte{
+		"非PCM编码":  mp3,
+		"采样率为0":   zeroRate,
+		"缺少data块": hdr.Bytes()[:36],
+		"不是WAV":   []byte("ID3\x04\x00\x00\x00\x00\x00\x00\x00\x00"),
+	}
+	for name, data := range tests {
+		path := filepath.Join(dir, "test.wav")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := probeWAV(path); err == nil {
+			t.Errorf("%s: probeWAV = %+v, 应返回错误", name, info)
+		}
+	}
+}
+
+func TestProbeFileWithoutFFprobe(t *testing.T) {
+	dir := t.TempDir()
+	s := &AudioService{}
+
+	wavPath := filepath.Join(dir, "a.wav")
+	writeTestWAV(t, wavPath, PCMFormat{SampleRate: 16000, Channels: 1}, 32000, 32000)
+	info, err := s.ProbeFile(context.Background(), wavPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "wav" || info.Size != 32044 || info.Duration != 1 || info.BitRate != 256000 {
+		t.Errorf("ProbeFile(wav) = %+v", *info)
+	}
+
+	// 50个数据包，未配置decoder时按每包20ms估算
+	var silkData bytes.Buffer
+	silkData.WriteString("\x02#!SILK_V3")
+	for i := 0; i < 50; i++ {
+		silkData.Write([]byte{0x02, 0x00, 0xAA, 0xBB})
+	}
+	silkPath := filepath.Join(dir, "a.silk")
+	if err := os.WriteFile(silkPath, silkData.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err = s.ProbeFile(context.Background(), silkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSize := int64(silkData.Len())
+	if info.Format != "silk" || info.Codec != "silk" || info.Duration != 1 || info.Size != wantSize || info.BitRate != wantSize*8 {
+		t.Errorf("ProbeFile(silk) = %+v", *info)
+	}
+}
+
+func TestParseFloatField(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   float64
+	}{
+		{[]string{"1.5"}, 1.5},
+		{[]string{"N/A", "", "2.25"}, 2.25},
+		{[]string{"0", "-1", "3"}, 3},
+		{[]string{"N/A"}, 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := parseFloatField(tt.values...); got != tt.want {
+			t.Errorf("parseFloatField(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestProbeSILKDecodedDuration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用shell脚本模拟decoder")
+	}
+	dir := t.TempDir()
+	// 模拟decoder输出2秒24kHz单声道PCM
+	decoder := filepath.Join(dir, "decoder")
+	script := "#!/bin/sh\nhead -c 96000 /dev/zero > \"$2\"\n"
+	if err := os.WriteFile(decoder, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 50个60ms数据包，按每包20ms估算只有1秒
+	var silkData bytes.Buffer
+	silkData.WriteString("\x02#!SILK_V3")
+	for i := 0; i < 50; i++ {
+		silkData.Write([]byte{0x02, 0x00, 0xAA, 0xBB})
+	}
+	silkPath := filepath.Join(dir, "a.silk")
+	if err := os.WriteFile(silkPath, silkData.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &AudioService{UploadDir: dir, DecoderPath: decoder}
+	info, err := s.ProbeFile(context.Background(), silkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Duration != 2 {
+		t.Errorf("Duration = %v, want 2", info.Duration)
+	}
+
+	// decoder失败时按数据包数量估算
+	s.DecoderPath = filepath.Join(dir, "missing")
+	if info, err = s.ProbeFile(context.Background(), silkPath); err != nil {
+		t.Fatal(err)
+	}
+	if info.Duration != 1 {
+		t.Errorf("Duration(无decoder) = %v, want 1", info.Duration)
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProgressFunc 转换进度回调，percent取值0~100
+type ProgressFunc func(percent float64)
+
+// ProgressDecoder 可选接口：能够报告解码进度的解码器
+type ProgressDecoder interface {
+	DecodeWithProgress(ctx context.Context, inputPath string, format PCMFormat, w io.Writer, progress ProgressFunc) error
+}
+
+// ffmpeg输出中输入时长的格式，例如 "Duration: 00:01:02.50, start: ..."
+var ffmpegDurationRe = regexp.MustCompile(`Duration:\s*(\d+:\d+:\d+(?:\.\d+)?)`)
+
+// ffmpeg -progress 输出的键名，这些行只用于计算进度，不写入日志
+var ffmpegProgressKeys = map[string]bool{
+	"frame": true, "fps": true, "bitrate": true, "total_size": true,
+	"out_time_us": true, "out_time_ms": true, "out_time": true,
+	"dup_frames": true, "drop_frames": true, "speed": true, "progress": true,
+}
+
+// ffmpegProgress 解析ffmpeg的stderr输出并换算为百分比进度
+type ffmpegProgress struct {
+	duration time.Duration
+	edit     EditOptions // 截取和变速会改变输出时长
+	report   ProgressFunc
+	last     float64
+}
+
+// handleLine 处理一行stderr输出，返回true表示该行为进度信息
+func (p *ffmpegProgress) handleLine(line string) bool {
+	if p.duration == 0 {
+		if m := ffmpegDurationRe.FindStringSubmatch(line); m != nil {
+			p.duration = p.edit.outputDuration(parseClock(m[1]))
+			return false
+		}
+	}
+
+	key, value, ok := strings.Cut(line, "=")
+	if !ok || !ffmpegProgressKeys[key] {
+		return false
+	}
+
+	switch key {
+	case "out_time_us", "out_time_ms":
+		// 两个字段的单位都是微秒（out_time_ms为ffmpeg的历史命名）
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+			p.update(time.Duration(us) * time.Microsecond)
+		}
+	case "progress":
+		if value == "end" {
+			p.emit(99)
+		}
+	}
+	return true
+}
+
+// update 根据已处理时长计算进度
+func (p *ffmpegProgress) update(done time.Duration) {
+	if p.duration <= 0 || done <= 0 {
+		return
+	}
+	percent := float64(done) / float64(p.duration) * 100
+	if percent > 99 {
+		// 100% 由流水线在输出文件发布后发出
+		percent = 99
+	}
+	p.emit(percent)
+}
+
+// emit 仅在进度至少前进1%时回调，避免事件过多
+func (p *ffmpegProgress) emit(percent float64) {
+	if percent < p.last+1 {
+		return
+	}
+	p.last = percent
+	p.report(percent)
+}
+
+// parseClock 解析 HH:MM:SS.xx 格式的时长
+func parseClock(s string) time.Duration {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(sec*float64(time.Second))
+}
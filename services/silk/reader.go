@@ -0,0 +1,74 @@
+package silk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// Reader 按SILK v3容器格式读取数据包
+type Reader struct {
+	r       *bufio.Reader
+	Tencent bool
+}
+
+// NewReader 创建SILK读取器并校验文件头
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	reader := &Reader{r: br}
+
+	if b, err := br.Peek(1); err == nil && b[0] == TencentPrefix {
+		br.ReadByte()
+		reader.Tencent = true
+	}
+
+	head := make([]byte, len(Header))
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	if string(head) != string(Header) {
+		return nil, ErrInvalidHeader
+	}
+	return reader, nil
+}
+
+// ReadPacket 读取下一个数据包，读到结束标记或文件末尾时返回 io.EOF
+func (r *Reader) ReadPacket() ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r.r, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	n := int16(binary.LittleEndian.Uint16(size[:]))
+	if n < 0 {
+		return nil, io.EOF
+	}
+
+	packet := make([]byte, n)
+	if _, err := io.ReadFull(r.r, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// CountPackets 统计数据包数量，可用于估算时长（每包20ms）
+func CountPackets(r io.Reader) (int, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		if _, err := reader.ReadPacket(); err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, err
+		}
+		count++
+	}
+}
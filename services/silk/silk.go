@@ -0,0 +1,66 @@
+// Package silk 提供SILK v3语音流的容器解析。
+//
+// SILK v3 文件由可选的腾讯前缀字节(0x02)、"#!SILK_V3" 文件头以及若干
+// "2字节小端长度 + 数据包" 组成；标准格式以长度 -1 (0xFFFF) 结尾，
+// 腾讯格式则省略结束标记。每个数据包包含1~5个20ms的帧，包时长由编码参数决定，容器中没有记录。
+package silk
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FrameDurationMs SILK帧时长（毫秒），也是默认的数据包时长，一个数据包可包含1~5帧
+const FrameDurationMs = 20
+
+// TencentPrefix 腾讯(微信/QQ)SILK文件的前缀字节
+const TencentPrefix byte = 0x02
+
+// Header SILK v3 标准文件头
+var Header = []byte("#!SILK_V3")
+
+// SupportedSampleRates 编码器支持的输入采样率
+var SupportedSampleRates = []int{8000, 12000, 16000, 24000}
+
+// ErrInvalidHeader SILK文件头无效
+var ErrInvalidHeader = errors.New("无效的SILK文件头")
+
+// ValidateSampleRate 检查采样率是否受支持
+func ValidateSampleRate(rate int) error {
+	for _, r := range SupportedSampleRates {
+		if r == rate {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的SILK采样率: %d", rate)
+}
+
+// NormalizeReader 在读取时统一SILK数据的文件头为标准的 #!SILK_V3
+// 支持带腾讯0x02前缀、标准头以及无文件头的裸SILK数据，只预读文件头，不会将整个文件读入内存
+func NormalizeReader(r *bufio.Reader) (io.Reader, error) {
+	if b, err := r.Peek(1); err == nil && b[0] == TencentPrefix {
+		r.Discard(1)
+	}
+	head, err := r.Peek(len(Header))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(head, Header) {
+		return r, nil
+	}
+	if len(head) < 2 {
+		return nil, fmt.Errorf("无效的SILK数据：文件过短")
+	}
+	return io.MultiReader(bytes.NewReader(Header), r), nil
+}
+
+// HasHeader 判断数据是否以SILK文件头开始（允许腾讯前缀）
+func HasHeader(data []byte) bool {
+	if len(data) > 0 && data[0] == TencentPrefix {
+		data = data[1:]
+	}
+	return bytes.HasPrefix(data, Header)
+}
@@ -0,0 +1,139 @@
+package silk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// encodeStream 按容器格式拼接数据包，tencent为true时带前缀且省略结束标记
+func encodeStream(tencent bool, packets ...[]byte) []byte {
+	var buf bytes.Buffer
+	if tencent {
+		buf.WriteByte(TencentPrefix)
+	}
+	buf.Write(Header)
+	for _, p := range packets {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(p)))
+		buf.Write(p)
+	}
+	if !tencent {
+		buf.Write([]byte{0xFF, 0xFF})
+	}
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	packets := [][]byte{{0x01, 0x02, 0x03}, {}, bytes.Repeat([]byte{0xAB}, 300)}
+	for _, tencent := range []bool{false, true} {
+		r, err := NewReader(bytes.NewReader(encodeStream(tencent, packets...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Tencent != tencent {
+			t.Errorf("Reader.Tencent = %v, want %v", r.Tencent, tencent)
+		}
+		for i, want := range packets {
+			got, err := r.ReadPacket()
+			if err != nil {
+				t.Fatalf("tencent=%v: 第%d个数据包: %v", tencent, i, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("tencent=%v: 第%d个数据包 = %x, want %x", tencent, i, got, want)
+			}
+		}
+		if _, err := r.ReadPacket(); err != io.EOF {
+			t.Errorf("tencent=%v: 读完后 = %v, want EOF", tencent, err)
+		}
+	}
+}
+
+func TestNewReaderInvalidHeader(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("#!SILK"), []byte("#!AMR\nxxxxxx"), {TencentPrefix}} {
+		if _, err := NewReader(bytes.NewReader(data)); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("NewReader(%q) = %v, want %v", data, err, ErrInvalidHeader)
+		}
+	}
+}
+
+func TestCountPackets(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"标准格式", []byte("#!SILK_V3\x02\x00ab\x01\x00c\xff\xff"), 2},
+		{"腾讯格式无结束标记", []byte("\x02#!SILK_V3\x02\x00ab\x01\x00c"), 2},
+		{"结束标记后的数据被忽略", []byte("#!SILK_V3\x01\x00a\xff\xffjunk"), 1},
+		{"末尾半个长度字段", []byte("#!SILK_V3\x01\x00a\x05"), 1},
+		{"空", []byte("#!SILK_V3"), 0},
+	}
+	for _, tt := range tests {
+		n, err := CountPackets(bytes.NewReader(tt.data))
+		if err != nil || n != tt.want {
+			t.Errorf("%s: CountPackets = %d, %v, want %d", tt.name, n, err, tt.want)
+		}
+	}
+
+	// 数据包被截断时返回已读取的数量和错误
+	n, err := CountPackets(bytes.NewReader([]byte("#!SILK_V3\x01\x00a\x05\x00ab")))
+	if n != 1 || err == nil {
+		t.Errorf("截断的数据包: CountPackets = %d, %v", n, err)
+	}
+}
+
+func TestNormalizeReader(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"#!SILK_V3\x01\x00a", "#!SILK_V3\x01\x00a"},
+		{"\x02#!SILK_V3\x01\x00a", "#!SILK_V3\x01\x00a"},
+		{"\x01\x00a", "#!SILK_V3\x01\x00a"},
+	}
+	for _, tt := range tests {
+		r, err := NormalizeReader(bufio.NewReader(strings.NewReader(tt.in)))
+		if err != nil {
+			t.Errorf("NormalizeReader(%q) = %v", tt.in, err)
+			continue
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("NormalizeReader(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := NormalizeReader(bufio.NewReader(bytes.NewReader([]byte{0x02, 0x01}))); err == nil {
+		t.Error("过短的数据应返回错误")
+	}
+}
+
+func TestHasHeader(t *testing.T) {
+	tests := map[string]bool{
+		"#!SILK_V3":      true,
+		"\x02#!SILK_V3":  true,
+		"\x02\x02#!SILK": false,
+		"#!SILK":         false,
+		"":               false,
+	}
+	for data, want := range tests {
+		if got := HasHeader([]byte(data)); got != want {
+			t.Errorf("HasHeader(%q) = %v, want %v", data, got, want)
+		}
+	}
+}
+
+func TestValidateSampleRate(t *testing.T) {
+	for _, rate := range SupportedSampleRates {
+		if err := ValidateSampleRate(rate); err != nil {
+			t.Errorf("ValidateSampleRate(%d) = %v", rate, err)
+		}
+	}
+	for _, rate := range []int{0, 44100, 48000} {
+		if err := ValidateSampleRate(rate); err == nil {
+			t.Errorf("ValidateSampleRate(%d) 应返回错误", rate)
+		}
+	}
+}
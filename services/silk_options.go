@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"audio-converter/services/silk"
+)
+
+// ErrInvalidOptions 转换参数不合法或编码器不支持
+var ErrInvalidOptions = errors.New("无效的转换参数")
+
+// SILK编码参数的取值范围，与 silk-v3-decoder encoder 保持一致
+const (
+	MinSilkBitRate    = 5000
+	MaxSilkBitRate    = 100000
+	MaxSilkComplexity = 2
+)
+
+// SupportedSilkPacketSizes 支持的数据包时长(ms)
+var SupportedSilkPacketSizes = []int{20, 40, 60, 80, 100}
+
+// SilkOptions 按请求设置的SILK编码参数，零值表示使用编码器默认值
+type SilkOptions struct {
+	SampleRate int   `json:"sample_rate" form:"sample_rate"` // 编码采样率: 8000/12000/16000/24000，0表示服务默认值
+	BitRate    int   `json:"bitrate" form:"bitrate"`         // 目标码率(bps)
+	Tencent    *bool `json:"tencent" form:"tencent"`         // 是否写入腾讯前缀字节，为空时使用编码器默认值
+	Complexity *int  `json:"complexity" form:"complexity"`   // 编码复杂度0~2，为空时为2
+	PacketLoss int   `json:"packet_loss" form:"packet_loss"` // 预期丢包率(%)，用于调整冗余
+	DTX        bool  `json:"dtx" form:"dtx"`                 // 静音段不输出数据（不连续传输）
+	PacketSize int   `json:"packet_size" form:"packet_size"` // 每个数据包的时长(ms)，0表示20ms
+}
+
+// SilkOptionsEncoder 可选接口：支持按请求设置SILK编码参数的编码器
+type SilkOptionsEncoder interface {
+	// CheckSilkOptions 检查编码器是否支持给定参数
+	CheckSilkOptions(opts SilkOptions) error
+	// EncodeSilk 按给定参数编码，format.SampleRate 已按 opts.SampleRate 设置
+	EncodeSilk(ctx context.Context, r io.Reader, outputPath string, format PCMFormat, opts SilkOptions) error
+}
+
+// Validate 检查参数取值范围，不涉及具体编码器
+func (o SilkOptions) Validate() error {
+	if o.SampleRate != 0 {
+		if err := silk.ValidateSampleRate(o.SampleRate); err != nil {
+			return fmt.Errorf("%w: sample_rate: %v", ErrInvalidOptions, err)
+		}
+	}
+	if o.BitRate != 0 && (o.BitRate < MinSilkBitRate || o.BitRate > MaxSilkBitRate) {
+		return fmt.Errorf("%w: bitrate 必须在 %d~%d 之间", ErrInvalidOptions, MinSilkBitRate, MaxSilkBitRate)
+	}
+	if o.Complexity != nil && (*o.Complexity < 0 || *o.Complexity > MaxSilkComplexity) {
+		return fmt.Errorf("%w: complexity 必须在 0~%d 之间", ErrInvalidOptions, MaxSilkComplexity)
+	}
+	if o.PacketLoss < 0 || o.PacketLoss > 100 {
+		return fmt.Errorf("%w: packet_loss 必须在 0~100 之间", ErrInvalidOptions)
+	}
+	if o.PacketSize != 0 && !containsInt(SupportedSilkPacketSizes, o.PacketSize) {
+		return fmt.Errorf("%w: packet_size 只能是 %v", ErrInvalidOptions, SupportedSilkPacketSizes)
+	}
+	return nil
+}
+
+// IsZero 判断是否未设置任何编码参数
+func (o SilkOptions) IsZero() bool {
+	return o.SampleRate == 0 && o.BitRate == 0 && o.Tencent == nil && o.Complexity == nil &&
+		o.PacketLoss == 0 && !o.DTX && o.PacketSize == 0
+}
+
+// tencent 返回是否写入腾讯前缀，未设置时使用def
+func (o SilkOptions) tencent(def bool) bool {
+	if o.Tencent == nil {
+		return def
+	}
+	return *o.Tencent
+}
+
+// cacheParams 返回参与缓存键计算的参数，未设置任何参数时为空以保持原有缓存键不变
+func (o SilkOptions) cacheParams() []string {
+	if o.IsZero() {
+		return nil
+	}
+	return []string{"silk:" + o.String()}
+}
+
+// String 返回已设置参数的描述，用于日志和缓存键
+func (o SilkOptions) String() string {
+	var parts []string
+	add := func(name string, v interface{}) {
+		parts = append(parts, fmt.Sprintf("%s=%v", name, v))
+	}
+	if o.SampleRate != 0 {
+		add("sample_rate", o.SampleRate)
+	}
+	if o.BitRate != 0 {
+		add("bitrate", o.BitRate)
+	}
+	if o.Tencent != nil {
+		add("tencent", *o.Tencent)
+	}
+	if o.Complexity != nil {
+		add("complexity", *o.Complexity)
+	}
+	if o.PacketLoss != 0 {
+		add("packet_loss", o.PacketLoss)
+	}
+	if o.DTX {
+		add("dtx", true)
+	}
+	if o.PacketSize != 0 {
+		add("packet_size", o.PacketSize)
+	}
+	if len(parts) == 0 {
+		return "默认"
+	}
+	return strings.Join(parts, " ")
+}
+
+// ValidateConvertOptions 检查转换参数、输出格式，以及所选编解码器是否存在、是否支持其中的SILK编码参数和音频处理参数
+func (s *AudioService) ValidateConvertOptions(opts ConvertOptions) error {
+	if err := opts.SilkOptions.Validate(); err != nil {
+		return err
+	}
+	if err := opts.SplitOptions.Validate(); err != nil {
+		return err
+	}
+	if err := opts.FilterOptions.Validate(); err != nil {
+		return err
+	}
+	decoderName := opts.Decoder
+	if decoderName == "" {
+		decoderName = "ffmpeg"
+	}
+	decoder, err := s.Registry.Decoder(decoderName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	if err := checkDecoderFilters(decoder, opts.FilterOptions); err != nil {
+		return err
+	}
+	encoderName, err := s.encoderName(opts)
+	if err != nil {
+		return err
+	}
+	encoder, err := s.Registry.Encoder(encoderName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	return checkEncoderOptions(encoder, opts.SilkOptions)
+}
+
+// checkEncoderOptions 检查编码器是否支持给定的SILK编码参数
+func checkEncoderOptions(encoder Encoder, opts SilkOptions) error {
+	if se, ok := encoder.(SilkOptionsEncoder); ok {
+		return se.CheckSilkOptions(opts)
+	}
+	// 不支持参数的编码器只接受采样率，采样率由解码器负责输出
+	opts.SampleRate = 0
+	if !opts.IsZero() {
+		return fmt.Errorf("%w: 编码器%s不支持SILK编码参数", ErrInvalidOptions, encoder.Name())
+	}
+	return nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSilkOptionsValidate(t *testing.T) {
+	zero, two, three, negative := 0, 2, 3, -1
+	tests := []struct {
+		opts SilkOptions
+		ok   bool
+	}{
+		{SilkOptions{}, true},
+		{SilkOptions{SampleRate: 16000, BitRate: 25000, Complexity: &two, PacketLoss: 10, DTX: true, PacketSize: 60}, true},
+		{SilkOptions{Complexity: &zero, PacketLoss: 100, PacketSize: 100}, true},
+		{SilkOptions{BitRate: MinSilkBitRate}, true},
+		{SilkOptions{BitRate: MaxSilkBitRate}, true},
+		{SilkOptions{SampleRate: 44100}, false},
+		{SilkOptions{BitRate: MinSilkBitRate - 1}, false},
+		{SilkOptions{BitRate: MaxSilkBitRate + 1}, false},
+		{SilkOptions{Complexity: &three}, false},
+		{SilkOptions{Complexity: &negative}, false},
+		{SilkOptions{PacketLoss: -1}, false},
+		{SilkOptions{PacketLoss: 101}, false},
+		{SilkOptions{PacketSize: 30}, false},
+		{SilkOptions{PacketSize: 120}, false},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("Validate(%s) = %v, want nil", tt.opts, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Validate(%s) = %v, want %v", tt.opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestSilkOptionsString(t *testing.T) {
+	yes, one := true, 1
+	if got := (SilkOptions{}).String(); got != "默认" {
+		t.Errorf("String() = %q, want 默认", got)
+	}
+	if got := (SilkOptions{}).cacheParams(); got != nil {
+		t.Errorf("cacheParams() = %v, want nil", got)
+	}
+
+	opts := SilkOptions{SampleRate: 16000, BitRate: 25000, Tencent: &yes, Complexity: &one, PacketLoss: 5, DTX: true, PacketSize: 40}
+	want := "sample_rate=16000 bitrate=25000 tencent=true complexity=1 packet_loss=5 dtx=true packet_size=40"
+	if got := opts.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := opts.cacheParams(); !reflect.DeepEqual(got, []string{"silk:" + want}) {
+		t.Errorf("cacheParams() = %v", got)
+	}
+}
+
+func TestSilkEncoderArgs(t *testing.T) {
+	no, zero := false, 0
+	format := PCMFormat{SampleRate: 24000, Channels: 1}
+	tests := []struct {
+		name    string
+		tencent bool
+		opts    SilkOptions
+		want    []string
+	}{
+		{"默认参数", false, SilkOptions{},
+			[]string{"in.pcm", "out.silk", "-Fs_API", "24000"}},
+		{"编码器默认腾讯前缀", true, SilkOptions{},
+			[]string{"in.pcm", "out.silk", "-Fs_API", "24000", "-tencent"}},
+		{"请求关闭腾讯前缀", true, SilkOptions{Tencent: &no},
+			[]string{"in.pcm", "out.silk", "-Fs_API", "24000"}},
+		{"全部参数", false, SilkOptions{BitRate: 25000, PacketSize: 60, Complexity: &zero, PacketLoss: 10, DTX: true},
+			[]string{"in.pcm", "out.silk", "-Fs_API", "24000", "-rate", "25000", "-packetlength", "60",
+				"-complexity", "0", "-loss", "10", "-DTX", "1"}},
+	}
+	for _, tt := range tests {
+		e := &SilkEncoder{Path: "encoder", Tencent: tt.tencent}
+		if got := e.args("in.pcm", "out.silk", format, tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: args() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckEncoderOptions(t *testing.T) {
+	two := 2
+	silkEncoder := &SilkEncoder{}
+	wavEncoder := &FFmpegEncoder{Format: OutputFormat{Ext: "wav"}}
+	if err := checkEncoderOptions(silkEncoder, SilkOptions{BitRate: 25000, Complexity: &two}); err != nil {
+		t.Errorf("SilkEncoder: %v", err)
+	}
+	if err := checkEncoderOptions(wavEncoder, SilkOptions{SampleRate: 16000}); err != nil {
+		t.Errorf("只设置采样率: %v", err)
+	}
+	if err := checkEncoderOptions(wavEncoder, SilkOptions{BitRate: 25000}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("不支持参数的编码器: %v, want %v", err, ErrInvalidOptions)
+	}
+}
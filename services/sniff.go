@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"audio-converter/services/silk"
+)
+
+// ErrUnsupportedMedia 输入内容不是可识别的音频格式
+var ErrUnsupportedMedia = errors.New("不支持的文件类型")
+
+// sniffLen 识别格式需要读取的文件头长度
+const sniffLen = 64
+
+// AudioFormat 通过文件头识别出的音频格式
+type AudioFormat struct {
+	Name string // 格式名称，如 mp3
+	Ext  string // 保存时使用的扩展名，含点号
+	MIME string
+}
+
+// 可识别的音频格式
+var (
+	FormatWAV  = AudioFormat{"wav", ".wav", "audio/wav"}
+	FormatMP3  = AudioFormat{"mp3", ".mp3", "audio/mpeg"}
+	FormatAAC  = AudioFormat{"aac", ".aac", "audio/aac"}
+	FormatOgg  = AudioFormat{"ogg", ".ogg", "audio/ogg"}
+	FormatOpus = AudioFormat{"opus", ".opus", "audio/ogg"}
+	FormatFLAC = AudioFormat{"flac", ".flac", "audio/flac"}
+	FormatM4A  = AudioFormat{"m4a", ".m4a", "audio/mp4"}
+	FormatMP4  = AudioFormat{"mp4", ".mp4", "video/mp4"}
+	FormatMOV  = AudioFormat{"mov", ".mov", "video/quicktime"}
+	FormatWebM = AudioFormat{"webm", ".webm", "video/webm"}
+	FormatMKV  = AudioFormat{"mkv", ".mkv", "video/x-matroska"}
+	FormatAMR  = AudioFormat{"amr", ".amr", "audio/amr"}
+	FormatAWB  = AudioFormat{"amr-wb", ".awb", "audio/amr-wb"}
+	FormatSILK = AudioFormat{"silk", ".silk", "audio/silk"}
+)
+
+// IsVideo 判断是否为视频容器，视频中的音频流需要通过ffprobe选择
+func (f AudioFormat) IsVideo() bool {
+	return strings.HasPrefix(f.MIME, "video/")
+}
+
+// SniffFormat 根据文件头识别音频格式
+func SniffFormat(header []byte) (AudioFormat, bool) {
+	switch {
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return FormatWAV, true
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return FormatMP3, true
+	case bytes.HasPrefix(header, []byte("OggS")):
+		if len(header) >= 36 && bytes.Equal(header[28:36], []byte("OpusHead")) {
+			return FormatOpus, true
+		}
+		return FormatOgg, true
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FormatFLAC, true
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		return sniffBrand(header)
+	case len(header) >= 8 && (bytes.Equal(header[4:8], []byte("moov")) || bytes.Equal(header[4:8], []byte("mdat")) ||
+		bytes.Equal(header[4:8], []byte("wide"))):
+		// 早期的QuickTime文件没有ftyp，直接以moov/mdat等atom开头
+		return FormatMOV, true
+	case bytes.HasPrefix(header, []byte("\x1a\x45\xdf\xa3")):
+		// EBML文件头中的DocType区分WebM和其他Matroska文件
+		if bytes.Contains(header, []byte("webm")) {
+			return FormatWebM, true
+		}
+		return FormatMKV, true
+	case bytes.HasPrefix(header, []byte("#!AMR-WB\n")):
+		return FormatAWB, true
+	case bytes.HasPrefix(header, []byte("#!AMR\n")):
+		return FormatAMR, true
+	case silk.HasHeader(header):
+		return FormatSILK, true
+	}
+	return sniffMPEGFrame(header)
+}
+
+// isoBrands 可接受的ISO-BMFF品牌，HEIC/AVIF等图片同样使用ftyp，需要按品牌区分
+var isoBrands = map[string]AudioFormat{
+	"M4A ": FormatM4A, "M4B ": FormatM4A, "M4P ": FormatM4A, "F4A ": FormatM4A,
+	"qt  ": FormatMOV,
+	"isom": FormatMP4, "iso2": FormatMP4, "iso3": FormatMP4, "iso4": FormatMP4, "iso5": FormatMP4, "iso6": FormatMP4,
+	"mp41": FormatMP4, "mp42": FormatMP4, "mp71": FormatMP4, "avc1": FormatMP4, "dash": FormatMP4,
+	"M4V ": FormatMP4, "M4VH": FormatMP4, "M4VP": FormatMP4, "F4V ": FormatMP4, "F4P ": FormatMP4,
+	"MSNV": FormatMP4, "NDAS": FormatMP4, "mmp4": FormatMP4, "kddi": FormatMP4,
+	"3gp4": FormatMP4, "3gp5": FormatMP4, "3gp6": FormatMP4, "3gp7": FormatMP4,
+	"3gg6": FormatMP4, "3ge6": FormatMP4, "3g2a": FormatMP4, "3g2b": FormatMP4, "3g2c": FormatMP4,
+}
+
+// sniffBrand 按ftyp中的主品牌识别，主品牌未知时再检查兼容品牌列表
+func sniffBrand(header []byte) (AudioFormat, bool) {
+	if format, ok := isoBrands[string(header[8:12])]; ok {
+		return format, true
+	}
+	// ftyp: 主品牌(4) + 次版本(4) + 兼容品牌(4*n)，只检查文件头中已读取的部分
+	size := int(binary.BigEndian.Uint32(header[0:4]))
+	if size > len(header) {
+		size = len(header)
+	}
+	for i := 16; i+4 <= size; i += 4 {
+		if format, ok := isoBrands[string(header[i:i+4])]; ok {
+			return format, true
+		}
+	}
+	return AudioFormat{}, false
+}
+
+// sniffMPEGFrame 识别没有ID3标签、直接以帧同步字开头的MPEG音频和ADTS AAC
+func sniffMPEGFrame(header []byte) (AudioFormat, bool) {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return AudioFormat{}, false
+	}
+	version := (header[1] >> 3) & 0x03
+	layer := (header[1] >> 1) & 0x03
+	if layer == 0 {
+		// ADTS：MPEG版本位只能是MPEG-4或MPEG-2
+		if header[1]&0xF6 == 0xF0 {
+			return FormatAAC, true
+		}
+		return AudioFormat{}, false
+	}
+	bitrate := header[2] >> 4
+	sampleRate := (header[2] >> 2) & 0x03
+	if version == 1 || bitrate == 0x0F || sampleRate == 0x03 {
+		return AudioFormat{}, false
+	}
+	return FormatMP3, true
+}
+
+// readHeader 读取文件开头用于识别格式的字节
+func readHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// SniffFile 识别文件的音频格式，无法识别时返回描述内容类型的 ErrUnsupportedMedia
+func SniffFile(path string) (AudioFormat, error) {
+	header, err := readHeader(path)
+	if err != nil {
+		return AudioFormat{}, err
+	}
+	return checkAudio(header)
+}
+
+// checkAudio 要求内容是可识别的音频格式
+func checkAudio(header []byte) (AudioFormat, error) {
+	if format, ok := SniffFormat(header); ok {
+		return format, nil
+	}
+	if err := rejectNonAudio(header); err != nil {
+		return AudioFormat{}, err
+	}
+	n := len(header)
+	if n > 16 {
+		n = 16
+	}
+	return AudioFormat{}, fmt.Errorf("%w: 未识别的音频格式（文件头 %s）", ErrUnsupportedMedia, hex.EncodeToString(header[:n]))
+}
+
+// checkSILK 检查SILK解码输入：拒绝其他音频格式和明显不是音频的内容，
+// 无法识别的内容按不带文件头的SILK数据处理
+func checkSILK(header []byte) error {
+	if format, ok := SniffFormat(header); ok {
+		if format != FormatSILK {
+			return fmt.Errorf("%w: 需要SILK文件，实际是%s格式", ErrUnsupportedMedia, format.Name)
+		}
+		return nil
+	}
+	return rejectNonAudio(header)
+}
+
+// rejectNonAudio 只拒绝明显不是音频的内容，用于尚不确定用途的上传和下载
+// 调用方应先用 SniffFormat 识别，品牌不在白名单中的ISO-BMFF文件（如HEIC/AVIF图片）同样拒绝
+func rejectNonAudio(header []byte) error {
+	if len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")) {
+		return fmt.Errorf("%w: 不支持的ISO媒体类型 %q，可能是HEIC/AVIF图片", ErrUnsupportedMedia, string(header[8:12]))
+	}
+	if desc, ok := describeNonAudio(header); ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMedia, desc)
+	}
+	return nil
+}
+
+// describeNonAudio 识别常见的非音频内容，便于客户端定位问题
+func describeNonAudio(header []byte) (string, bool) {
+	trimmed := bytes.ToLower(bytes.TrimLeft(header, " \t\r\n\xef\xbb\xbf"))
+	switch {
+	case len(header) == 0:
+		return "文件为空", true
+	case bytes.HasPrefix(trimmed, []byte("<!doctype html")), bytes.HasPrefix(trimmed, []byte("<html")):
+		return "内容是HTML页面，请检查URL是否指向音频文件", true
+	case bytes.HasPrefix(trimmed, []byte("<?xml")):
+		return "内容是XML文本", true
+	case bytes.HasPrefix(trimmed, []byte("{")), bytes.HasPrefix(trimmed, []byte("[")):
+		return "内容是JSON文本", true
+	case bytes.HasPrefix(header, []byte("%PDF")):
+		return "内容是PDF文档", true
+	case bytes.HasPrefix(header, []byte("\x89PNG")), bytes.HasPrefix(header, []byte("\xff\xd8\xff")), bytes.HasPrefix(header, []byte("GIF8")):
+		return "内容是图片", true
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return "内容是ZIP压缩包", true
+	}
+	return "", false
+}
+
+// sniffWriter 在写入过程中收集文件头，收满后立即识别，明显不是音频时中止写入
+type sniffWriter struct {
+	w      io.Writer
+	header []byte
+	done   bool
+	format AudioFormat
+	ok     bool
+}
+
+func (sw *sniffWriter) Write(p []byte) (int, error) {
+	if !sw.done {
+		n := sniffLen - len(sw.header)
+		if n > len(p) {
+			n = len(p)
+		}
+		sw.header = append(sw.header, p[:n]...)
+		if len(sw.header) == sniffLen {
+			if err := sw.sniff(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return sw.w.Write(p)
+}
+
+// sniff 识别已收集的文件头，内容不足 sniffLen 时在写入结束后调用
+func (sw *sniffWriter) sniff() error {
+	if sw.done {
+		return nil
+	}
+	sw.done = true
+	sw.format, sw.ok = SniffFormat(sw.header)
+	if sw.ok {
+		return nil
+	}
+	return rejectNonAudio(sw.header)
+}
+
+// storeInput 将内容写入上传目录并按识别出的格式设置扩展名，明显不是音频的内容会被拒绝
+func (s *AudioService) storeInput(write func(w io.Writer) error) (string, error) {
+	path := s.UploadPath("")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	sw := &sniffWriter{w: file}
+	err = write(sw)
+	if err == nil {
+		err = sw.sniff()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if sw.ok {
+		named := path + sw.format.Ext
+		if err := os.Rename(path, named); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		path = named
+	}
+	return path, nil
+}
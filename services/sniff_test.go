@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// ftyp 构造ISO-BMFF文件头，compatible为兼容品牌列表
+func ftyp(major string, compatible ...string) []byte {
+	size := 16 + 4*len(compatible)
+	header := []byte{0, 0, 0, byte(size)}
+	header = append(header, "ftyp"+major+"\x00\x00\x00\x00"...)
+	for _, brand := range compatible {
+		header = append(header, brand...)
+	}
+	return header
+}
+
+func TestSniffFormat(t *testing.T) {
+	opus := append([]byte("OggS"), make([]byte, 24)...)
+	opus = append(opus, "OpusHead"...)
+	tests := []struct {
+		name   string
+		header []byte
+		want   AudioFormat
+	}{
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), FormatWAV},
+		{"mp3 ID3", []byte("ID3\x04\x00"), FormatMP3},
+		{"mp3 帧同步", []byte{0xFF, 0xFB, 0x90, 0x64}, FormatMP3},
+		{"aac ADTS", []byte{0xFF, 0xF1, 0x50, 0x80}, FormatAAC},
+		{"ogg vorbis", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00"), FormatOgg},
+		{"ogg opus", opus, FormatOpus},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), FormatFLAC},
+		{"m4a", ftyp("M4A ", "M4A ", "mp42", "isom"), FormatM4A},
+		{"mp4", ftyp("isom", "isom", "iso2", "mp41"), FormatMP4},
+		{"3gp", ftyp("3gp4", "3gp4"), FormatMP4},
+		{"mov", ftyp("qt  ", "qt  "), FormatMOV},
+		{"兼容品牌", ftyp("XXXX", "mp42"), FormatMP4},
+		{"无ftyp的QuickTime", []byte("\x00\x00\x00\x08wide"), FormatMOV},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), FormatWebM},
+		{"mkv", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska"), FormatMKV},
+		{"amr", []byte("#!AMR\n\x3c"), FormatAMR},
+		{"amr-wb", []byte("#!AMR-WB\n\x24"), FormatAWB},
+		{"silk", []byte("#!SILK_V3\x0a\x00"), FormatSILK},
+		{"腾讯silk", []byte("\x02#!SILK_V3\x0a\x00"), FormatSILK},
+	}
+	for _, tt := range tests {
+		got, ok := SniffFormat(tt.header)
+		if !ok || got != tt.want {
+			t.Errorf("%s: SniffFormat = %v, %v, want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestSniffFormatRejects(t *testing.T) {
+	tests := map[string][]byte{
+		"空":        nil,
+		"heic":     ftyp("heic", "mif1", "heic"),
+		"avif":     ftyp("avif", "mif1", "miaf"),
+		"坏的帧头":     {0xFF, 0xFB, 0xF0, 0x64},
+		"MPEG保留版本": {0xFF, 0xEB, 0x90, 0x64},
+		"文本":       []byte("hello world"),
+	}
+	for name, header := range tests {
+		if got, ok := SniffFormat(header); ok {
+			t.Errorf("%s: SniffFormat = %v, want 未识别", name, got)
+		}
+	}
+}
+
+func TestCheckAudio(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   error
+	}{
+		{"mp3", []byte("ID3\x04\x00"), nil},
+		{"heic", ftyp("heic", "mif1", "heic"), ErrUnsupportedMedia},
+		{"avif", ftyp("avif", "mif1"), ErrUnsupportedMedia},
+		{"html", []byte("\xef\xbb\xbf  <!DOCTYPE html><html>"), ErrUnsupportedMedia},
+		{"json", []byte(`{"error":"not found"}`), ErrUnsupportedMedia},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), ErrUnsupportedMedia},
+		{"jpeg", []byte("\xff\xd8\xff\xe0"), ErrUnsupportedMedia},
+		{"zip", []byte("PK\x03\x04"), ErrUnsupportedMedia},
+		{"空", nil, ErrUnsupportedMedia},
+		{"未知", bytes.Repeat([]byte{0x11}, 32), ErrUnsupportedMedia},
+	}
+	for _, tt := range tests {
+		_, err := checkAudio(tt.header)
+		if tt.want == nil && err != nil {
+			t.Errorf("%s: checkAudio = %v, want nil", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkAudio = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCheckSILK(t *testing.T) {
+	if err := checkSILK([]byte("#!SILK_V3\x0a\x00")); err != nil {
+		t.Errorf("checkSILK(silk) = %v", err)
+	}
+	// 不带文件头的SILK数据无法识别，按SILK处理
+	if err := checkSILK([]byte{0x0a, 0x00, 0x11, 0x22}); err != nil {
+		t.Errorf("checkSILK(无文件头) = %v", err)
+	}
+	if err := checkSILK([]byte("ID3\x04\x00")); !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("checkSILK(mp3) = %v, want %v", err, ErrUnsupportedMedia)
+	}
+	if err := checkSILK(ftyp("avif", "mif1")); !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("checkSILK(avif) = %v, want %v", err, ErrUnsupportedMedia)
+	}
+}
+
+func TestSniffWriter(t *testing.T) {
+	var buf bytes.Buffer
+	sw := &sniffWriter{w: &buf}
+	if _, err := sw.Write([]byte("<html><body>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.sniff(); !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("sniff(html) = %v, want %v", err, ErrUnsupportedMedia)
+	}
+
+	// 收满文件头后立即识别，非音频内容中止写入
+	sw = &sniffWriter{w: &bytes.Buffer{}}
+	if _, err := sw.Write(bytes.Repeat([]byte("<html>"), sniffLen)); !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("Write(html) = %v, want %v", err, ErrUnsupportedMedia)
+	}
+
+	sw = &sniffWriter{w: &bytes.Buffer{}}
+	data := append([]byte("fLaC"), make([]byte, sniffLen)...)
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := sw.Write(data[i:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.sniff(); err != nil || !sw.ok || sw.format != FormatFLAC {
+		t.Errorf("分块写入flac: %v, %v, %v", err, sw.ok, sw.format)
+	}
+}
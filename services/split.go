@@ -0,0 +1,316 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"audio-converter/utils"
+)
+
+// MaxSplitSeconds 分段时长上限（秒）
+const MaxSplitSeconds = 3600
+
+const (
+	// silenceFrameMs 静音检测的帧长
+	silenceFrameMs = 20
+	// silenceSearchMs 在分段点之前寻找静音的最长范围
+	silenceSearchMs = 5000
+	// silenceThreshold 静音判定阈值（RMS），约为 -40 dBFS
+	silenceThreshold = 328
+)
+
+// SplitOptions 分段转换选项
+type SplitOptions struct {
+	// SplitSeconds 每段最长时长（秒），0表示不分段；微信和QQ语音不超过60秒
+	SplitSeconds int `json:"split_seconds" form:"split_seconds"`
+	// SplitOnSilence 在分段点之前最近的静音处切分，找不到静音时按时长切分
+	SplitOnSilence bool `json:"split_on_silence" form:"split_on_silence"`
+}
+
+// Validate 检查分段选项
+func (o SplitOptions) Validate() error {
+	if o.SplitSeconds < 0 || o.SplitSeconds > MaxSplitSeconds {
+		return fmt.Errorf("%w: split_seconds 必须在 0~%d 之间", ErrInvalidOptions, MaxSplitSeconds)
+	}
+	if o.SplitOnSilence && o.SplitSeconds == 0 {
+		return fmt.Errorf("%w: split_on_silence 需要同时设置 split_seconds", ErrInvalidOptions)
+	}
+	return nil
+}
+
+// SplitManifest 分段转换清单，与各分段一起保存在输出目录
+type SplitManifest struct {
+	Original       string         `json:"original,omitempty"`
+	SplitSeconds   int            `json:"split_seconds"`
+	SplitOnSilence bool           `json:"split_on_silence"`
+	Duration       float64        `json:"duration"` // 总时长（秒）
+	CreatedAt      time.Time      `json:"created_at"`
+	Parts          []ManifestPart `json:"parts"`
+}
+
+// ManifestPart 清单中的一个分段
+type ManifestPart struct {
+	Index    int     `json:"index"` // 从1开始
+	Filename string  `json:"filename"`
+	Name     string  `json:"name"`
+	Start    float64 `json:"start"`    // 在原音频中的起始时间（秒）
+	Duration float64 `json:"duration"` // 分段时长（秒）
+	Size     int64   `json:"size"`
+}
+
+// splitReadSize 分段时每次从解码器读取的PCM字节数
+const splitReadSize = 32 << 10
+
+// encodeSplit 按时长切分PCM流，每段单独编码为一个输出文件，最后写出清单文件
+// 每段边读边编码，内存中只保留分段末尾用于寻找静音的数据；
+// 返回结果的 Filename 指向清单，Parts 按顺序列出各分段
+func (s *AudioService) encodeSplit(ctx context.Context, r io.Reader, encoder Encoder, format PCMFormat, opts ConvertOptions) (*ConvertResult, error) {
+	bytesPerSecond := format.SampleRate * format.Channels * 2
+	frameBytes := bytesPerSecond * silenceFrameMs / 1000
+	chunkBytes := opts.SplitSeconds * bytesPerSecond
+	searchBytes := bytesPerSecond * silenceSearchMs / 1000
+	if searchBytes > chunkBytes/2 {
+		searchBytes = chunkBytes / 2 / frameBytes * frameBytes
+	}
+
+	ext := encoder.Ext()
+	baseName := renderName(opts.NamingOptions, newRandomID(), ext, time.Now())
+	var parts []*ConvertResult
+	manifest := SplitManifest{
+		Original:       opts.OriginalName,
+		SplitSeconds:   opts.SplitSeconds,
+		SplitOnSilence: opts.SplitOnSilence,
+		CreatedAt:      time.Now(),
+	}
+	// 任一分段失败时删除已生成的分段，不留下不完整的结果
+	ok := false
+	defer func() {
+		if !ok {
+			for _, part := range parts {
+				s.removeOutput(part.Filename)
+			}
+		}
+	}()
+
+	// emit 编码一个分段，write 向编码器写入该段的PCM并返回写入的字节数
+	emit := func(write func(w io.Writer) (int, error)) error {
+		index := len(parts) + 1
+		filename := newRandomID() + "." + ext
+		outputPath := filepath.Join(s.SilkDir, filename)
+		tmpPath := tempOutputPath(outputPath)
+		defer os.Remove(tmpPath)
+
+		pr, pw := io.Pipe()
+		encodeErr := make(chan error, 1)
+		go func() {
+			err := s.encodePCM(ctx, encoder, pr, tmpPath, format, opts.SilkOptions)
+			// 编码器提前退出时关闭读端，避免写入方阻塞在管道上
+			pr.CloseWithError(errors.New("编码器已退出"))
+			encodeErr <- err
+		}()
+		n, err := write(pw)
+		pw.CloseWithError(err)
+		if encErr := <-encodeErr; encErr != nil {
+			return fmt.Errorf("第%d段编码失败: %w", index, encErr)
+		}
+		if err != nil {
+			return err
+		}
+		if err := commitOutput(tmpPath, outputPath); err != nil {
+			return fmt.Errorf("第%d段输出文件未生成: %v", index, err)
+		}
+		part := s.publishResult(ctx, filename, partName(baseName, index), opts.NamingOptions, false)
+		// 按PCM长度计算的时长比解析SILK数据包更准确
+		part.AudioDuration = roundSeconds(float64(n) / float64(bytesPerSecond))
+		manifest.Parts = append(manifest.Parts, ManifestPart{
+			Index:    index,
+			Filename: part.Filename,
+			Name:     part.Name,
+			Start:    manifest.Duration,
+			Duration: part.AudioDuration,
+			Size:     part.Size,
+		})
+		manifest.Duration = roundSeconds(manifest.Duration + part.AudioDuration)
+		parts = append(parts, part)
+		utils.Debug("已生成第%d段: %s (%.2f秒)", index, filename, part.AudioDuration)
+		return nil
+	}
+
+	hold := 0
+	if opts.SplitOnSilence {
+		hold = searchBytes
+	}
+	src := bufio.NewReaderSize(r, splitReadSize)
+	var carry []byte
+	for len(carry) > 0 || !atEOF(src) {
+		err := emit(func(w io.Writer) (int, error) {
+			var n int
+			var err error
+			n, carry, err = splitChunk(w, src, carry, chunkBytes, hold, frameBytes)
+			return n, err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("解码结果为空")
+	}
+
+	result, err := s.writeManifest(manifest, baseName, opts.NamingOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		result.Size += part.Size
+	}
+	result.AudioDuration = manifest.Duration
+	result.Parts = parts
+	ok = true
+	return result, nil
+}
+
+// splitChunk 将一个分段的PCM写入w：先写入上一段切分点之后的数据carry，再从r读取，直到满chunkBytes或读完；
+// hold大于0时最后hold字节暂不写入，在其中寻找静音作为切分点。返回写入的字节数和留给下一段的数据
+func splitChunk(w io.Writer, r io.Reader, carry []byte, chunkBytes, hold, frameBytes int) (int, []byte, error) {
+	pending := carry
+	written := 0
+	eof := false
+	buf := make([]byte, splitReadSize)
+	for written+len(pending) < chunkBytes {
+		want := chunkBytes - written - len(pending)
+		if want > len(buf) {
+			want = len(buf)
+		}
+		n, err := io.ReadFull(r, buf[:want])
+		pending = append(pending, buf[:n]...)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			eof = true
+			break
+		} else if err != nil {
+			return written, nil, err
+		}
+		if len(pending) > hold {
+			k := len(pending) - hold
+			if _, err := w.Write(pending[:k]); err != nil {
+				return written, nil, err
+			}
+			written += k
+			pending = append(pending[:0], pending[k:]...)
+		}
+	}
+
+	cut := len(pending)
+	if !eof && hold > 0 {
+		cut = findSilence(pending, frameBytes, hold)
+	}
+	if _, err := w.Write(pending[:cut]); err != nil {
+		return written, nil, err
+	}
+	return written + cut, append([]byte(nil), pending[cut:]...), nil
+}
+
+// atEOF 判断r中是否已没有数据，读取出错时返回false，由后续读取报告错误
+func atEOF(r *bufio.Reader) bool {
+	_, err := r.Peek(1)
+	return err == io.EOF
+}
+
+// encodePCM 使用编码器编码PCM，支持SILK参数的编码器按请求参数编码
+func (s *AudioService) encodePCM(ctx context.Context, encoder Encoder, r io.Reader, outputPath string, format PCMFormat, opts SilkOptions) error {
+	if se, ok := encoder.(SilkOptionsEncoder); ok {
+		return se.EncodeSilk(ctx, r, outputPath, format, opts)
+	}
+	return encoder.Encode(ctx, r, outputPath, format)
+}
+
+// findSilence 在pcm末尾的搜索范围内寻找最安静的帧，返回切分位置（该帧结束处）
+// 范围内没有低于阈值的帧时返回len(pcm)，即按时长切分
+func findSilence(pcm []byte, frameBytes, searchBytes int) int {
+	best, bestRMS := len(pcm), math.MaxFloat64
+	for end := len(pcm); end-frameBytes >= len(pcm)-searchBytes && end-frameBytes >= 0; end -= frameBytes {
+		rms := frameRMS(pcm[end-frameBytes : end])
+		// 同样安静时优先靠后的位置，使分段尽量接近目标时长
+		if rms < bestRMS {
+			best, bestRMS = end, rms
+		}
+	}
+	if bestRMS > silenceThreshold {
+		return len(pcm)
+	}
+	return best
+}
+
+// frameRMS 计算一帧16位PCM的均方根
+func frameRMS(frame []byte) float64 {
+	n := len(frame) / 2
+	if n == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		v := float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// partName 为分段生成下载文件名，在扩展名前加上序号，如 voice_01.silk
+func partName(name string, index int) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_%02d%s", strings.TrimSuffix(name, ext), index, ext)
+}
+
+// writeManifest 将清单写入输出目录，返回指向清单的转换结果
+func (s *AudioService) writeManifest(manifest SplitManifest, baseName string, opts NamingOptions) (*ConvertResult, error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	filename := newRandomID() + ".json"
+	name := strings.TrimSuffix(baseName, filepath.Ext(baseName)) + "_manifest.json"
+	outputPath := filepath.Join(s.SilkDir, filename)
+	tmpPath := tempOutputPath(outputPath)
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("写入分段清单失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("写入分段清单失败: %v", err)
+	}
+	meta := OutputMeta{Filename: filename, Name: name, Original: opts.OriginalName, CreatedAt: manifest.CreatedAt}
+	if err := s.writeMeta(meta); err != nil {
+		utils.Warn("写入文件元数据失败: %s: %v", filename, err)
+	}
+	return &ConvertResult{Filename: filename, Name: name, Original: opts.OriginalName}, nil
+}
+
+// removeSplit 删除分段转换的全部输出，用于编码完成后解码阶段报错的情况
+func (s *AudioService) removeSplit(result *ConvertResult) {
+	for _, part := range result.Parts {
+		s.removeOutput(part.Filename)
+	}
+	s.removeOutput(result.Filename)
+}
+
+// removeOutput 删除输出文件及其元数据
+func (s *AudioService) removeOutput(filename string) {
+	os.Remove(filepath.Join(s.SilkDir, filename))
+	os.Remove(MetaPath(s.SilkDir, filename))
+}
+
+// roundSeconds 将秒数保留到毫秒
+func roundSeconds(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// pcmFrames 生成16位单声道PCM，levels中每个值对应一帧的恒定采样值
+func pcmFrames(frameBytes int, levels ...int16) []byte {
+	var buf bytes.Buffer
+	for _, level := range levels {
+		for i := 0; i < frameBytes/2; i++ {
+			binary.Write(&buf, binary.LittleEndian, level)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestFindSilence(t *testing.T) {
+	const frame = 8
+	tests := []struct {
+		name   string
+		pcm    []byte
+		search int
+		want   int
+	}{
+		{"无静音按时长切分", pcmFrames(frame, 5000, 5000, 5000, 5000), 4 * frame, 4 * frame},
+		{"切在静音帧之后", pcmFrames(frame, 5000, 0, 5000, 5000), 4 * frame, 2 * frame},
+		{"同样安静时取靠后的位置", pcmFrames(frame, 0, 5000, 0, 5000), 4 * frame, 3 * frame},
+		{"选最安静的帧", pcmFrames(frame, 5000, 100, 5000, 200), 4 * frame, 2 * frame},
+		{"只在搜索范围内查找", pcmFrames(frame, 0, 5000, 5000, 5000), 3 * frame, 4 * frame},
+		{"末尾静音", pcmFrames(frame, 5000, 5000, 5000, 0), 4 * frame, 4 * frame},
+		{"空", nil, 4 * frame, 0},
+	}
+	for _, tt := range tests {
+		if got := findSilence(tt.pcm, frame, tt.search); got != tt.want {
+			t.Errorf("%s: findSilence = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFrameRMS(t *testing.T) {
+	if got := frameRMS(pcmFrames(8, 1000)); got != 1000 {
+		t.Errorf("frameRMS(1000) = %v", got)
+	}
+	if got := frameRMS(pcmFrames(8, -1000)); got != 1000 {
+		t.Errorf("frameRMS(-1000) = %v", got)
+	}
+	if got := frameRMS(nil); got != 0 {
+		t.Errorf("frameRMS(nil) = %v", got)
+	}
+}
+
+func TestSplitChunk(t *testing.T) {
+	const frame = 8
+	// 10帧：第6帧静音，其余为声音
+	pcm := pcmFrames(frame, 5000, 5000, 5000, 5000, 5000, 0, 5000, 5000, 5000, 5000)
+
+	t.Run("按时长切分", func(t *testing.T) {
+		r := bufio.NewReader(bytes.NewReader(pcm))
+		var chunks [][]byte
+		var carry []byte
+		for len(carry) > 0 || !atEOF(r) {
+			var w bytes.Buffer
+			n, rest, err := splitChunk(&w, r, carry, 4*frame, 0, frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != w.Len() {
+				t.Errorf("返回的字节数 %d 与写入的 %d 不一致", n, w.Len())
+			}
+			chunks = append(chunks, w.Bytes())
+			carry = rest
+		}
+		if len(chunks) != 3 || len(chunks[0]) != 4*frame || len(chunks[1]) != 4*frame || len(chunks[2]) != 2*frame {
+			t.Errorf("分段长度不正确: %d 段", len(chunks))
+		}
+		if !bytes.Equal(bytes.Join(chunks, nil), pcm) {
+			t.Error("分段拼接后与原数据不一致")
+		}
+	})
+
+	t.Run("在静音处切分", func(t *testing.T) {
+		r := bufio.NewReader(bytes.NewReader(pcm))
+		var w bytes.Buffer
+		n, carry, err := splitChunk(&w, r, nil, 8*frame, 4*frame, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 6*frame || !bytes.Equal(w.Bytes(), pcm[:6*frame]) {
+			t.Errorf("第1段 = %d 字节, want %d", n, 6*frame)
+		}
+		if !bytes.Equal(carry, pcm[6*frame:8*frame]) {
+			t.Errorf("留给下一段的数据 = %d 字节, want %d", len(carry), 2*frame)
+		}
+
+		w.Reset()
+		n, carry, err = splitChunk(&w, r, carry, 8*frame, 4*frame, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 4*frame || len(carry) != 0 || !bytes.Equal(w.Bytes(), pcm[6*frame:]) {
+			t.Errorf("最后一段 = %d 字节, carry %d, want %d", n, len(carry), 4*frame)
+		}
+		if !atEOF(r) {
+			t.Error("应已读完输入")
+		}
+	})
+
+	t.Run("写入失败", func(t *testing.T) {
+		r := bufio.NewReader(bytes.NewReader(pcm))
+		_, _, err := splitChunk(failWriter{}, r, nil, 4*frame, 0, frame)
+		if !errors.Is(err, errWriteFailed) {
+			t.Errorf("splitChunk = %v, want %v", err, errWriteFailed)
+		}
+	})
+}
+
+var errWriteFailed = errors.New("写入失败")
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errWriteFailed }
+
+func TestSplitOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts SplitOptions
+		ok   bool
+	}{
+		{SplitOptions{}, true},
+		{SplitOptions{SplitSeconds: 60}, true},
+		{SplitOptions{SplitSeconds: 60, SplitOnSilence: true}, true},
+		{SplitOptions{SplitSeconds: MaxSplitSeconds}, true},
+		{SplitOptions{SplitSeconds: -1}, false},
+		{SplitOptions{SplitSeconds: MaxSplitSeconds + 1}, false},
+		{SplitOptions{SplitOnSilence: true}, false},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("Validate(%+v) = %v", tt.opts, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestPartName(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		want  string
+	}{
+		{"voice.silk", 1, "voice_01.silk"},
+		{"voice.silk", 12, "voice_12.silk"},
+		{"a.b.mp3", 3, "a.b_03.mp3"},
+		{"noext", 100, "noext_100"},
+	}
+	for _, tt := range tests {
+		if got := partName(tt.name, tt.index); got != tt.want {
+			t.Errorf("partName(%q, %d) = %q, want %q", tt.name, tt.index, got, tt.want)
+		}
+	}
+}
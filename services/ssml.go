@@ -0,0 +1,362 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 韵律参数的取值范围
+const (
+	MinTTSRate   = 0.5
+	MaxTTSRate   = 2.0
+	MaxTTSPitch  = 12.0 // 半音
+	MinTTSVolume = 0.1
+	MaxTTSVolume = 2.0
+
+	// maxBreak 单个停顿的最长时长
+	maxBreak = 10 * time.Second
+	// maxTotalBreak 一次请求中全部停顿的总时长上限，字数上限只计算原始SSML，限制不了停顿生成的静音
+	maxTotalBreak = 60 * time.Second
+)
+
+// Prosody 韵律参数：语速倍数、音调偏移（半音）和音量倍数
+type Prosody struct {
+	Rate   float64
+	Pitch  float64
+	Volume float64
+}
+
+// defaultProsody 引擎默认的韵律
+var defaultProsody = Prosody{Rate: 1, Volume: 1}
+
+// clamp 将参数限制在允许范围内，SSML中的嵌套调整可能超出范围
+func (p Prosody) clamp() Prosody {
+	p.Rate = math.Max(MinTTSRate, math.Min(MaxTTSRate, p.Rate))
+	p.Pitch = math.Max(-MaxTTSPitch, math.Min(MaxTTSPitch, p.Pitch))
+	p.Volume = math.Max(MinTTSVolume, math.Min(MaxTTSVolume, p.Volume))
+	return p
+}
+
+// ttsSegment 一段使用相同韵律合成的文本
+type ttsSegment struct {
+	Text    string
+	Prosody Prosody
+	Pause   time.Duration // 该段之前的停顿
+}
+
+// isSSML 判断文本是否为SSML
+func isSSML(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "<speak")
+}
+
+// parseSSML 解析SSML，支持 <speak>、<break>、<prosody>，<p> 和 <s> 视为句子边界，其他标签只保留文本
+func parseSSML(text string, base Prosody) ([]ttsSegment, error) {
+	dec := xml.NewDecoder(strings.NewReader(text))
+	dec.Strict = false
+
+	var segments []ttsSegment
+	stack := []Prosody{base}
+	var buf strings.Builder
+	var pause, totalPause time.Duration
+
+	// flush 将已收集的文本作为一段输出
+	flush := func() {
+		if t := strings.TrimSpace(buf.String()); t != "" {
+			segments = append(segments, ttsSegment{Text: t, Prosody: stack[len(stack)-1], Pause: pause})
+			pause = 0
+		}
+		buf.Reset()
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: SSML解析失败: %v", ErrInvalidOptions, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "break":
+				flush()
+				d, err := parseBreak(t.Attr)
+				if err != nil {
+					return nil, err
+				}
+				if pause+d > maxBreak {
+					d = maxBreak - pause
+				}
+				pause += d
+				totalPause += d
+				if totalPause > maxTotalBreak {
+					return nil, fmt.Errorf("%w: SSML中的停顿总时长超过%s", ErrInvalidOptions, maxTotalBreak)
+				}
+			case "prosody":
+				flush()
+				p, err := applyProsody(stack[len(stack)-1], t.Attr)
+				if err != nil {
+					return nil, err
+				}
+				stack = append(stack, p)
+			case "p", "s":
+				flush()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "prosody":
+				flush()
+				if len(stack) > 1 {
+					stack = stack[:len(stack)-1]
+				}
+			case "p", "s":
+				flush()
+			}
+		case xml.CharData:
+			buf.Write(t)
+		}
+	}
+	flush()
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("%w: SSML中没有可合成的文本", ErrInvalidOptions)
+	}
+	if pause > 0 {
+		// 结尾的停顿
+		segments = append(segments, ttsSegment{Pause: pause})
+	}
+	return segments, nil
+}
+
+// 停顿强度对应的时长
+var breakStrengths = map[string]time.Duration{
+	"none":     0,
+	"x-weak":   100 * time.Millisecond,
+	"weak":     250 * time.Millisecond,
+	"medium":   500 * time.Millisecond,
+	"strong":   750 * time.Millisecond,
+	"x-strong": 1200 * time.Millisecond,
+}
+
+// parseBreak 解析 <break time="500ms"/> 或 <break strength="strong"/>
+func parseBreak(attrs []xml.Attr) (time.Duration, error) {
+	if v := xmlAttr(attrs, "time"); v != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("%w: 无效的break时长: %q", ErrInvalidOptions, v)
+		}
+		return d, nil
+	}
+	if v := xmlAttr(attrs, "strength"); v != "" {
+		d, ok := breakStrengths[v]
+		if !ok {
+			return 0, fmt.Errorf("%w: 无效的break强度: %q", ErrInvalidOptions, v)
+		}
+		return d, nil
+	}
+	return breakStrengths["medium"], nil
+}
+
+// 韵律关键字对应的取值
+var (
+	rateKeywords   = map[string]float64{"x-slow": 0.5, "slow": 0.75, "medium": 1, "default": 1, "fast": 1.25, "x-fast": 1.5}
+	pitchKeywords  = map[string]float64{"x-low": -6, "low": -3, "medium": 0, "default": 0, "high": 3, "x-high": 6}
+	volumeKeywords = map[string]float64{"silent": MinTTSVolume, "x-soft": 0.25, "soft": 0.5, "medium": 1, "default": 1, "loud": 1.5, "x-loud": 2}
+)
+
+// applyProsody 在当前韵律上叠加 <prosody> 的属性
+func applyProsody(p Prosody, attrs []xml.Attr) (Prosody, error) {
+	if v := xmlAttr(attrs, "rate"); v != "" {
+		f, err := parseScale(v, rateKeywords)
+		if err != nil {
+			return p, fmt.Errorf("%w: 无效的prosody rate: %q", ErrInvalidOptions, v)
+		}
+		p.Rate *= f
+	}
+	if v := xmlAttr(attrs, "pitch"); v != "" {
+		st, err := parsePitch(v)
+		if err != nil {
+			return p, fmt.Errorf("%w: 无效的prosody pitch: %q", ErrInvalidOptions, v)
+		}
+		p.Pitch += st
+	}
+	if v := xmlAttr(attrs, "volume"); v != "" {
+		f, err := parseVolume(v)
+		if err != nil {
+			return p, fmt.Errorf("%w: 无效的prosody volume: %q", ErrInvalidOptions, v)
+		}
+		p.Volume *= f
+	}
+	return p.clamp(), nil
+}
+
+// parseScale 解析倍数：关键字、百分比（120%、+20%）或小数（1.2）
+func parseScale(v string, keywords map[string]float64) (float64, error) {
+	v = strings.TrimSpace(v)
+	if f, ok := keywords[v]; ok {
+		return f, nil
+	}
+	if strings.HasSuffix(v, "%") {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil {
+			return 0, err
+		}
+		// 带符号的百分比表示相对调整，否则表示绝对比例
+		if strings.HasPrefix(v, "+") || strings.HasPrefix(v, "-") {
+			return 1 + n/100, nil
+		}
+		return n / 100, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("无效的倍数")
+	}
+	return f, nil
+}
+
+// parsePitch 解析音调：关键字、半音（+2st）或百分比（+10%）
+func parsePitch(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	if st, ok := pitchKeywords[v]; ok {
+		return st, nil
+	}
+	if strings.HasSuffix(v, "st") {
+		return strconv.ParseFloat(strings.TrimSuffix(v, "st"), 64)
+	}
+	f, err := parseScale(v, nil)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("无效的音调")
+	}
+	return 12 * math.Log2(f), nil
+}
+
+// parseVolume 解析音量：关键字、分贝（+6dB）或百分比
+func parseVolume(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	if f, ok := volumeKeywords[v]; ok {
+		return f, nil
+	}
+	if strings.HasSuffix(strings.ToLower(v), "db") {
+		db, err := strconv.ParseFloat(v[:len(v)-2], 64)
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(10, db/20), nil
+	}
+	return parseScale(v, nil)
+}
+
+// xmlAttr 按名称读取属性
+func xmlAttr(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// chunkText 按句子将文本切分为不超过maxRunes个字符的片段，
+// 单句过长时先在逗号等停顿处切分，仍然过长时按字符数切分
+func chunkText(text string, maxRunes int) []string {
+	var chunks []string
+	var cur strings.Builder
+	curLen := 0
+	add := func(piece string) {
+		n := utf8.RuneCountInString(piece)
+		if curLen > 0 && curLen+n > maxRunes {
+			chunks = append(chunks, strings.TrimSpace(cur.String()))
+			cur.Reset()
+			curLen = 0
+		}
+		cur.WriteString(piece)
+		curLen += n
+	}
+	for _, sentence := range splitAfter(text, isSentenceEnd) {
+		if utf8.RuneCountInString(sentence) <= maxRunes {
+			add(sentence)
+			continue
+		}
+		for _, clause := range splitAfter(sentence, isClauseEnd) {
+			for utf8.RuneCountInString(clause) > maxRunes {
+				cut := runeOffset(clause, maxRunes)
+				add(clause[:cut])
+				clause = clause[cut:]
+			}
+			add(clause)
+		}
+	}
+	if t := strings.TrimSpace(cur.String()); t != "" {
+		chunks = append(chunks, t)
+	}
+	// 去掉只有标点或空白的片段，部分引擎遇到空文本会报错
+	result := chunks[:0]
+	for _, c := range chunks {
+		if strings.IndexFunc(c, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// splitAfter 在满足end的位置之后切分，保留分隔符；英文句点只有后跟空白或位于末尾时才算句末
+func splitAfter(text string, end func(r rune, next rune) bool) []string {
+	var parts []string
+	start := 0
+	runes := []rune(text)
+	offset := 0
+	for i, r := range runes {
+		offset += utf8.RuneLen(r)
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		if end(r, next) {
+			parts = append(parts, text[start:offset])
+			start = offset
+		}
+	}
+	if start < len(text) {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
+
+// isSentenceEnd 判断是否为句末标点
+func isSentenceEnd(r, next rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '…', '!', '?', ';', '\n':
+		return true
+	case '.':
+		return next == 0 || unicode.IsSpace(next)
+	}
+	return false
+}
+
+// isClauseEnd 判断是否为句中停顿标点
+func isClauseEnd(r, next rune) bool {
+	switch r {
+	case '，', '、', '：', ',', ':':
+		return true
+	}
+	return isSentenceEnd(r, next)
+}
+
+// runeOffset 返回第n个字符的字节偏移
+func runeOffset(s string, n int) int {
+	i := 0
+	for offset := range s {
+		if i == n {
+			return offset
+		}
+		i++
+	}
+	return len(s)
+}
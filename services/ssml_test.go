@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseSSML(t *testing.T) {
+	text := `<speak>你好。<break time="300ms"/><prosody rate="150%" pitch="+2st">快一点<prosody volume="loud">大声</prosody></prosody><p>第二段</p><break strength="strong"/></speak>`
+	got, err := parseSSML(text, defaultProsody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast := Prosody{Rate: 1.5, Pitch: 2, Volume: 1}
+	want := []ttsSegment{
+		{Text: "你好。", Prosody: defaultProsody},
+		{Text: "快一点", Prosody: fast, Pause: 300 * time.Millisecond},
+		{Text: "大声", Prosody: Prosody{Rate: 1.5, Pitch: 2, Volume: 1.5}},
+		{Text: "第二段", Prosody: defaultProsody},
+		{Pause: 750 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSSML =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseSSMLBreaks(t *testing.T) {
+	tests := []struct {
+		name  string
+		ssml  string
+		pause time.Duration
+		err   bool
+	}{
+		{"默认强度", `<speak><break/>a</speak>`, 500 * time.Millisecond, false},
+		{"连续停顿累加", `<speak><break time="1s"/><break time="2s"/>a</speak>`, 3 * time.Second, false},
+		{"单个停顿上限", `<speak><break time="1h"/>a</speak>`, maxBreak, false},
+		{"连续停顿合计上限", `<speak><break time="8s"/><break time="8s"/>a</speak>`, maxBreak, false},
+		{"无效时长", `<speak><break time="abc"/>a</speak>`, 0, true},
+		{"负数时长", `<speak><break time="-1s"/>a</speak>`, 0, true},
+		{"无效强度", `<speak><break strength="huge"/>a</speak>`, 0, true},
+	}
+	for _, tt := range tests {
+		segments, err := parseSSML(tt.ssml, defaultProsody)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("%s: parseSSML = %v, want %v", tt.name, err, ErrInvalidOptions)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseSSML = %v", tt.name, err)
+			continue
+		}
+		if segments[0].Pause != tt.pause {
+			t.Errorf("%s: 停顿 = %s, want %s", tt.name, segments[0].Pause, tt.pause)
+		}
+	}
+
+	// 停顿总时长超过上限时拒绝，避免少量SSML生成大量静音
+	var b strings.Builder
+	b.WriteString("<speak>")
+	for i := 0; i <= int(maxTotalBreak/maxBreak); i++ {
+		b.WriteString(`<break time="10s"/>字`)
+	}
+	b.WriteString("</speak>")
+	if _, err := parseSSML(b.String(), defaultProsody); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("停顿总时长超限: parseSSML = %v, want %v", err, ErrInvalidOptions)
+	}
+}
+
+func TestParseSSMLInvalid(t *testing.T) {
+	tests := []string{
+		`<speak><break time="1s"/></speak>`,
+		`<speak>   </speak>`,
+		`<speak><prosody rate="abc">a</prosody></speak>`,
+		`<speak><prosody pitch="0%">a</prosody></speak>`,
+		`<speak><prosody volume="xdB">a</prosody></speak>`,
+	}
+	for _, text := range tests {
+		if _, err := parseSSML(text, defaultProsody); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("parseSSML(%s) = %v, want %v", text, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestApplyProsodyClamp(t *testing.T) {
+	text := `<speak><prosody rate="x-fast"><prosody rate="x-fast"><prosody rate="x-fast" pitch="+20st" volume="+20dB">a</prosody></prosody></prosody></speak>`
+	segments, err := parseSSML(text, defaultProsody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Prosody{Rate: MaxTTSRate, Pitch: MaxTTSPitch, Volume: MaxTTSVolume}
+	if segments[0].Prosody != want {
+		t.Errorf("嵌套调整后的韵律 = %+v, want %+v", segments[0].Prosody, want)
+	}
+}
+
+func TestParseProsodyValues(t *testing.T) {
+	scales := map[string]float64{"slow": 0.75, "120%": 1.2, "+20%": 1.2, "-50%": 0.5, "1.5": 1.5}
+	for v, want := range scales {
+		if got, err := parseScale(v, rateKeywords); err != nil || math.Abs(got-want) > 1e-9 {
+			t.Errorf("parseScale(%q) = %v, %v, want %v", v, got, err, want)
+		}
+	}
+	pitches := map[string]float64{"high": 3, "+2st": 2, "-1.5st": -1.5, "200%": 12}
+	for v, want := range pitches {
+		if got, err := parsePitch(v); err != nil || math.Abs(got-want) > 1e-9 {
+			t.Errorf("parsePitch(%q) = %v, %v, want %v", v, got, err, want)
+		}
+	}
+	volumes := map[string]float64{"soft": 0.5, "+20dB": 10, "-20db": 0.1, "50%": 0.5}
+	for v, want := range volumes {
+		if got, err := parseVolume(v); err != nil || math.Abs(got-want) > 1e-9 {
+			t.Errorf("parseVolume(%q) = %v, %v, want %v", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"", "fast!", "0", "-1"} {
+		if _, err := parseScale(v, rateKeywords); err == nil {
+			t.Errorf("parseScale(%q) 应返回错误", v)
+		}
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want []string
+	}{
+		{"不需要切分", "你好。世界！", 10, []string{"你好。世界！"}},
+		{"按句子切分", "第一句。第二句。第三句。", 8, []string{"第一句。第二句。", "第三句。"}},
+		{"英文句点后跟空白才切分", "Version 1.5 is out. Try it.", 20, []string{"Version 1.5 is out.", "Try it."}},
+		{"长句在逗号处切分", "一二三四五，六七八九十，甲乙丙。", 8, []string{"一二三四五，", "六七八九十，", "甲乙丙。"}},
+		{"仍然过长时按字符切分", "一二三四五六七八九十", 4, []string{"一二三四", "五六七八", "九十"}},
+		{"去掉只有标点的片段", "一二。！？！？", 3, []string{"一二。"}},
+		{"空文本", "", 10, nil},
+	}
+	for _, tt := range tests {
+		got := chunkText(tt.text, tt.max)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: chunkText = %q, want %q", tt.name, got, tt.want)
+		}
+		for _, c := range got {
+			if n := utf8.RuneCountInString(c); n > tt.max {
+				t.Errorf("%s: 片段 %q 长度 %d 超过 %d", tt.name, c, n, tt.max)
+			}
+		}
+	}
+}
+
+func TestTTSRequestSegments(t *testing.T) {
+	req := TTSRequest{Text: "  <speak>你好</speak>", Rate: 1.5}
+	segments, err := req.segments()
+	if err != nil || len(segments) != 1 || segments[0].Text != "你好" || segments[0].Prosody.Rate != 1.5 {
+		t.Errorf("自动识别SSML: %+v, %v", segments, err)
+	}
+	req = TTSRequest{Text: " 1 < 2 "}
+	segments, err = req.segments()
+	if err != nil || len(segments) != 1 || segments[0].Text != "1 < 2" {
+		t.Errorf("纯文本: %+v, %v", segments, err)
+	}
+}
+
+func TestTTSRequestValidate(t *testing.T) {
+	tests := []struct {
+		req TTSRequest
+		ok  bool
+	}{
+		{TTSRequest{Text: "你好"}, true},
+		{TTSRequest{Text: "你好", Rate: 2, Pitch: -12, Volume: 0.1}, true},
+		{TTSRequest{Text: " "}, false},
+		{TTSRequest{Text: "一二三四五六"}, false},
+		{TTSRequest{Text: "你好", Rate: 3}, false},
+		{TTSRequest{Text: "你好", Pitch: 13}, false},
+		{TTSRequest{Text: "你好", Volume: 0.05}, false},
+	}
+	for _, tt := range tests {
+		err := tt.req.validate(5)
+		if tt.ok && err != nil {
+			t.Errorf("validate(%+v) = %v", tt.req, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("validate(%+v) = %v, want %v", tt.req, err, ErrInvalidOptions)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"audio-converter/utils"
+)
+
+// ErrNoAudioStream 文件（通常是视频）中没有音频流
+var ErrNoAudioStream = errors.New("文件中没有音频流")
+
+// AudioStream 文件中的一条音频流
+type AudioStream struct {
+	Index      int    `json:"index"` // 在音频流中的序号（从0开始），即 audio_stream 参数的取值
+	Codec      string `json:"codec,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+	BitRate    int64  `json:"bit_rate,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Default    bool   `json:"default,omitempty"` // 容器标记的默认音轨
+}
+
+// bestAudioStream 选择最适合转换的音频流：优先默认音轨，其次声道数、采样率和比特率更高的流
+func bestAudioStream(streams []AudioStream) int {
+	best := 0
+	for i := 1; i < len(streams); i++ {
+		if betterStream(streams[i], streams[best]) {
+			best = i
+		}
+	}
+	return streams[best].Index
+}
+
+// betterStream 判断a是否优于b，完全相同时保留序号靠前的流
+func betterStream(a, b AudioStream) bool {
+	switch {
+	case a.Default != b.Default:
+		return a.Default
+	case a.Channels != b.Channels:
+		return a.Channels > b.Channels
+	case a.SampleRate != b.SampleRate:
+		return a.SampleRate > b.SampleRate
+	default:
+		return a.BitRate > b.BitRate
+	}
+}
+
+// selectAudioStream 视频文件或指定了音频流时读取流信息并确定要转换的音频流
+// 文件中没有音频流时返回 ErrNoAudioStream，指定的音频流不存在时返回 ErrInvalidOptions；
+// 视频中有多条音频流且未指定时，若解码器支持则选择最佳的一条写入opts
+func (s *AudioService) selectAudioStream(ctx context.Context, inputPath string, format AudioFormat, decoder Decoder, opts *FilterOptions) error {
+	if !format.IsVideo() && opts.AudioStream == nil {
+		return nil
+	}
+	info, err := s.ProbeFile(ctx, inputPath)
+	if err != nil {
+		if errors.Is(err, ErrNoAudioStream) || opts.AudioStream != nil || ctx.Err() != nil {
+			return err
+		}
+		// 读取失败时（如未安装ffprobe）仍交给解码器处理，由ffmpeg自动选择音频流
+		utils.Warn("读取音频流信息失败，由解码器自动选择: %v", err)
+		return nil
+	}
+	count := len(info.Streams)
+	if count == 0 {
+		// WAV和SILK直接解析文件，只有一条音频流
+		count = 1
+	}
+	if opts.AudioStream != nil {
+		if *opts.AudioStream >= count {
+			return fmt.Errorf("%w: audio_stream %d 不存在，文件中共有%d条音频流", ErrInvalidOptions, *opts.AudioStream, count)
+		}
+		return nil
+	}
+	if _, ok := decoder.(FilterDecoder); ok && count > 1 {
+		best := bestAudioStream(info.Streams)
+		opts.AudioStream = &best
+	}
+	if info.Video {
+		utils.Info("从%s视频中提取音频, 共%d条音频流", format.Name, count)
+	}
+	if opts.AudioStream != nil {
+		utils.Debug("选择第%d条音频流", *opts.AudioStream)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"audio-converter/utils"
+)
+
+// 语音识别错误
+var (
+	// ErrTranscribeUnavailable 未配置语音识别引擎
+	ErrTranscribeUnavailable = errors.New("未配置语音识别引擎")
+	// ErrTranscribeTimeout 语音识别超时
+	ErrTranscribeTimeout = errors.New("语音识别超时")
+)
+
+// transcribeFormat 语音识别引擎的输入格式：16kHz单声道
+var transcribeFormat = PCMFormat{SampleRate: 16000, Channels: 1}
+
+// 识别结果的输出格式
+const (
+	TranscriptJSON = "json"
+	TranscriptSRT  = "srt"
+	TranscriptVTT  = "vtt"
+)
+
+// TranscribeOptions 语音识别选项
+type TranscribeOptions struct {
+	Language string `json:"language" form:"language"` // 语言代码，如 zh、en，为空时自动检测
+	Format   string `json:"format" form:"format"`     // 输出格式: json/srt/vtt，默认json
+
+	AudioStream *int `json:"audio_stream" form:"audio_stream"` // 视频或多音轨文件中要识别的音频流，为空时自动选择
+}
+
+// Validate 检查选项取值
+func (o TranscribeOptions) Validate() error {
+	switch strings.ToLower(o.Format) {
+	case "", TranscriptJSON, TranscriptSRT, TranscriptVTT:
+	default:
+		return fmt.Errorf("%w: 不支持的识别结果格式: %s", ErrInvalidOptions, o.Format)
+	}
+	if o.AudioStream != nil && *o.AudioStream < 0 {
+		return fmt.Errorf("%w: audio_stream 不能为负数", ErrInvalidOptions)
+	}
+	for _, r := range o.Language {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+			return fmt.Errorf("%w: 无效的语言代码: %q", ErrInvalidOptions, o.Language)
+		}
+	}
+	return nil
+}
+
+// TranscriptSegment 带时间戳的识别片段
+type TranscriptSegment struct {
+	Start float64 `json:"start"` // 起始时间（秒）
+	End   float64 `json:"end"`   // 结束时间（秒）
+	Text  string  `json:"text"`
+}
+
+// Transcript 语音识别结果
+type Transcript struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language,omitempty"` // 识别出或指定的语言
+	Duration float64             `json:"duration"`           // 音频时长（秒）
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+// SRT 将识别结果格式化为SRT字幕
+func (t *Transcript) SRT() string {
+	var b strings.Builder
+	for i, seg := range t.Segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatTimestamp(seg.Start, ","), formatTimestamp(seg.End, ","), seg.Text)
+	}
+	return b.String()
+}
+
+// VTT 将识别结果格式化为WebVTT字幕
+func (t *Transcript) VTT() string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, seg := range t.Segments {
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n",
+			formatTimestamp(seg.Start, "."), formatTimestamp(seg.End, "."), seg.Text)
+	}
+	return b.String()
+}
+
+// formatTimestamp 格式化字幕时间戳 HH:MM:SS,mmm，SRT与VTT只有毫秒分隔符不同
+func formatTimestamp(seconds float64, sep string) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// Transcriber 语音识别引擎
+type Transcriber interface {
+	// Name 返回引擎名称
+	Name() string
+	// Transcribe 识别16kHz单声道WAV文件，ctx取消时应尽快退出
+	Transcribe(ctx context.Context, wavPath string, opts TranscribeOptions) (*Transcript, error)
+}
+
+// Transcribe 将输入音频解码为16kHz PCM后进行语音识别，支持SILK和ffmpeg可解码的格式
+// 识别与转换共用转换槽位，UploadInput 类型的输入在返回时总会被删除
+func (s *AudioService) Transcribe(ctx context.Context, input Input, opts TranscribeOptions) (*Transcript, error) {
+	defer s.DiscardInput(input)
+	if s.Transcriber == nil {
+		return nil, ErrTranscribeUnavailable
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.CheckInput(input); err != nil {
+		return nil, err
+	}
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.release()
+
+	inputPath, cleanup, err := s.resolveInput(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	wavPath, pcmBytes, err := s.decodeForTranscribe(ctx, inputPath, opts.AudioStream)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(wavPath)
+
+	transcribeCtx, cancel := stageContext(ctx, s.TranscribeTimeout)
+	defer cancel()
+	start := time.Now()
+	transcript, err := s.Transcriber.Transcribe(transcribeCtx, wavPath, opts)
+	if err := stageError(ctx, transcribeCtx, err, ErrTranscribeTimeout); err != nil {
+		return nil, fmt.Errorf("语音识别失败: %w", err)
+	}
+	transcript.Duration = roundSeconds(float64(pcmBytes) / float64(transcribeFormat.SampleRate*transcribeFormat.Channels*2))
+	utils.Info("%s语音识别完成: %d个片段, 语言: %s (耗时: %.2f秒)",
+		s.Transcriber.Name(), len(transcript.Segments), transcript.Language, time.Since(start).Seconds())
+	return transcript, nil
+}
+
+// decodeForTranscribe 将输入解码为16kHz单声道WAV，返回WAV路径和PCM字节数
+// SILK先由外部decoder解码为PCM，再与其他格式一样交给ffmpeg重采样，视频按音频流选择规则取一条音频流
+func (s *AudioService) decodeForTranscribe(ctx context.Context, inputPath string, audioStream *int) (string, int64, error) {
+	inputFormat, err := SniffFile(inputPath)
+	if err != nil {
+		return "", 0, err
+	}
+	utils.Debug("输入格式: %s", inputFormat.Name)
+	if inputFormat == FormatSILK {
+		silkWAV := s.UploadPath(".wav")
+		defer os.Remove(silkWAV)
+		_, err := writeWAVFile(silkWAV, PCMFormat{SampleRate: silkPCMRate, Channels: 1}, func(w io.Writer) error {
+			return s.decodeSILK(ctx, inputPath, w)
+		})
+		if err != nil {
+			return "", 0, err
+		}
+		inputPath = silkWAV
+	}
+
+	decoder, err := s.Registry.Decoder("ffmpeg")
+	if err != nil {
+		return "", 0, err
+	}
+	filters := FilterOptions{AudioStream: audioStream}
+	if err := s.selectAudioStream(ctx, inputPath, inputFormat, decoder, &filters); err != nil {
+		return "", 0, err
+	}
+	decodeCtx, cancel := stageContext(ctx, s.DecodeTimeout)
+	defer cancel()
+	wavPath := s.UploadPath(".wav")
+	n, err := writeWAVFile(wavPath, transcribeFormat, func(w io.Writer) error {
+		return decodeInput(decodeCtx, decoder, inputPath, transcribeFormat, w, ConvertOptions{FilterOptions: filters})
+	})
+	if err := stageError(ctx, decodeCtx, err, ErrDecodeTimeout); err != nil {
+		return "", 0, fmt.Errorf("PCM转换失败: %w", err)
+	}
+	if n == 0 {
+		os.Remove(wavPath)
+		return "", 0, fmt.Errorf("%w: 输入中没有音频数据", ErrInvalidInput)
+	}
+	utils.Info("%s解码为16kHz PCM完成", decoder.Name())
+	return wavPath, n, nil
+}
+
+// WhisperTranscriber 调用 whisper.cpp 命令行程序识别语音
+type WhisperTranscriber struct {
+	Path     string
+	Model    string // ggml模型文件
+	Threads  int    // 线程数，0表示使用whisper.cpp的默认值
+	Language string // 默认语言，为空时自动检测
+}
+
+// whisperOutput whisper.cpp -oj 输出中需要的字段
+type whisperOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // 毫秒
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+// Name 返回引擎名称
+func (t *WhisperTranscriber) Name() string { return "whisper" }
+
+// Transcribe 结果以JSON写入与输入同名的文件，读取后删除
+func (t *WhisperTranscriber) Transcribe(ctx context.Context, wavPath string, opts TranscribeOptions) (*Transcript, error) {
+	language := firstNonEmpty(opts.Language, t.Language, "auto")
+	outPrefix := strings.TrimSuffix(wavPath, ".wav")
+	args := []string{"-m", t.Model, "-f", wavPath, "-l", language, "-oj", "-of", outPrefix, "-np"}
+	if t.Threads > 0 {
+		args = append(args, "-t", strconv.Itoa(t.Threads))
+	}
+	jsonPath := outPrefix + ".json"
+	defer os.Remove(jsonPath)
+	if err := runCommand("whisper", exec.CommandContext(ctx, t.Path, args...)); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取识别结果失败: %v", err)
+	}
+	var out whisperOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析识别结果失败: %v", err)
+	}
+
+	transcript := &Transcript{Language: out.Result.Language, Segments: []TranscriptSegment{}}
+	if transcript.Language == "" && language != "auto" {
+		transcript.Language = language
+	}
+	var text strings.Builder
+	for _, seg := range out.Transcription {
+		// 静音片段会被识别为 [BLANK_AUDIO] 之类的标记
+		trimmed := strings.TrimSpace(seg.Text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			continue
+		}
+		text.WriteString(seg.Text)
+		transcript.Segments = append(transcript.Segments, TranscriptSegment{
+			Start: float64(seg.Offsets.From) / 1000,
+			End:   float64(seg.Offsets.To) / 1000,
+			Text:  trimmed,
+		})
+	}
+	transcript.Text = strings.TrimSpace(text.String())
+	return transcript, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		sep     string
+		want    string
+	}{
+		{0, ",", "00:00:00,000"},
+		{1.5, ",", "00:00:01,500"},
+		{61.0004, ".", "00:01:01.000"},
+		{59.9996, ".", "00:01:00.000"},
+		{3723.456, ",", "01:02:03,456"},
+		{36000, ".", "10:00:00.000"},
+	}
+	for _, tt := range tests {
+		if got := formatTimestamp(tt.seconds, tt.sep); got != tt.want {
+			t.Errorf("formatTimestamp(%g, %q) = %q, want %q", tt.seconds, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestTranscriptSubtitles(t *testing.T) {
+	transcript := &Transcript{Segments: []TranscriptSegment{
+		{Start: 0, End: 1.25, Text: "你好"},
+		{Start: 1.25, End: 62, Text: "世界"},
+	}}
+	wantSRT := "1\n00:00:00,000 --> 00:00:01,250\n你好\n\n" +
+		"2\n00:00:01,250 --> 00:01:02,000\n世界\n\n"
+	if got := transcript.SRT(); got != wantSRT {
+		t.Errorf("SRT =\n%s\nwant\n%s", got, wantSRT)
+	}
+	wantVTT := "WEBVTT\n\n" +
+		"00:00:00.000 --> 00:00:01.250\n你好\n\n" +
+		"00:00:01.250 --> 00:01:02.000\n世界\n\n"
+	if got := transcript.VTT(); got != wantVTT {
+		t.Errorf("VTT =\n%s\nwant\n%s", got, wantVTT)
+	}
+	if got := (&Transcript{}).VTT(); got != "WEBVTT\n\n" {
+		t.Errorf("空结果的VTT = %q", got)
+	}
+}
+
+func TestTranscribeOptionsValidate(t *testing.T) {
+	stream := func(i int) *int { return &i }
+	tests := []struct {
+		opts TranscribeOptions
+		ok   bool
+	}{
+		{TranscribeOptions{}, true},
+		{TranscribeOptions{Language: "zh", Format: "SRT"}, true},
+		{TranscribeOptions{Language: "zh-CN", Format: TranscriptVTT, AudioStream: stream(0)}, true},
+		{TranscribeOptions{Format: "txt"}, false},
+		{TranscribeOptions{AudioStream: stream(-1)}, false},
+		{TranscribeOptions{Language: "zh; rm -rf /"}, false},
+		{TranscribeOptions{Language: "../en"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("Validate(%+v) = %v", tt.opts, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.opts, err, ErrInvalidOptions)
+		}
+	}
+}
@@ -0,0 +1,637 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"audio-converter/utils"
+)
+
+// 文本转语音错误
+var (
+	// ErrTTSUnavailable 没有可用的TTS引擎
+	ErrTTSUnavailable = errors.New("未配置TTS引擎")
+	// ErrTTSTimeout 语音合成超时
+	ErrTTSTimeout = errors.New("语音合成超时")
+	// ErrTTSProvider TTS服务返回错误
+	ErrTTSProvider = errors.New("TTS服务返回错误")
+)
+
+const (
+	// DefaultMaxTTSChars 单次请求的默认文本长度上限（字符）
+	DefaultMaxTTSChars = 5000
+	// DefaultTTSChunkChars 单次提交给引擎的默认文本长度（字符）
+	DefaultTTSChunkChars = 200
+)
+
+// maxTTSAudioBytes HTTP引擎返回音频的大小上限
+const maxTTSAudioBytes = 100 << 20
+
+// TTSRequest 语音合成请求
+type TTSRequest struct {
+	Text     string  `json:"text" form:"text"`
+	Provider string  `json:"provider" form:"provider"` // 引擎名称，为空时使用默认引擎
+	Voice    string  `json:"voice" form:"voice"`       // 发音人，为空时使用引擎配置的默认值
+	Rate     float64 `json:"rate" form:"rate"`         // 语速倍数 0.5~2，0表示默认
+	Pitch    float64 `json:"pitch" form:"pitch"`       // 音调偏移（半音）-12~12
+	Volume   float64 `json:"volume" form:"volume"`     // 音量倍数 0.1~2，0表示默认
+	SSML     bool    `json:"ssml" form:"ssml"`         // 按SSML解析text，以 <speak> 开头时自动识别
+}
+
+// prosody 返回请求的韵律，未设置的项使用默认值
+func (r TTSRequest) prosody() Prosody {
+	p := defaultProsody
+	if r.Rate != 0 {
+		p.Rate = r.Rate
+	}
+	p.Pitch = r.Pitch
+	if r.Volume != 0 {
+		p.Volume = r.Volume
+	}
+	return p
+}
+
+// validate 检查文本长度和韵律参数
+func (r TTSRequest) validate(maxChars int) error {
+	if strings.TrimSpace(r.Text) == "" {
+		return fmt.Errorf("%w: text 不能为空", ErrInvalidOptions)
+	}
+	if n := utf8.RuneCountInString(r.Text); n > maxChars {
+		return fmt.Errorf("%w: 文本长度%d超过上限%d", ErrInvalidOptions, n, maxChars)
+	}
+	if r.Rate != 0 && (r.Rate < MinTTSRate || r.Rate > MaxTTSRate) {
+		return fmt.Errorf("%w: rate 必须在 %g~%g 之间", ErrInvalidOptions, MinTTSRate, MaxTTSRate)
+	}
+	if r.Pitch < -MaxTTSPitch || r.Pitch > MaxTTSPitch {
+		return fmt.Errorf("%w: pitch 必须在 %g~%g 之间", ErrInvalidOptions, -MaxTTSPitch, MaxTTSPitch)
+	}
+	if r.Volume != 0 && (r.Volume < MinTTSVolume || r.Volume > MaxTTSVolume) {
+		return fmt.Errorf("%w: volume 必须在 %g~%g 之间", ErrInvalidOptions, MinTTSVolume, MaxTTSVolume)
+	}
+	return nil
+}
+
+// segments 将请求文本解析为按韵律分组的文本段
+func (r TTSRequest) segments() ([]ttsSegment, error) {
+	if r.SSML || isSSML(r.Text) {
+		return parseSSML(r.Text, r.prosody())
+	}
+	return []ttsSegment{{Text: strings.TrimSpace(r.Text), Prosody: r.prosody()}}, nil
+}
+
+// TTSProvider 文本转语音引擎
+type TTSProvider interface {
+	// Name 返回引擎名称，用于注册和按请求选择
+	Name() string
+	// Synthesize 合成一段纯文本并将音频文件内容写入w，格式须能被解码器识别
+	// req.Rate、Pitch、Volume 只在引擎通过 ProsodyTTS 声明支持时设置
+	Synthesize(ctx context.Context, req TTSRequest, w io.Writer) error
+}
+
+// ProsodySupport 引擎能直接调整的韵律参数
+type ProsodySupport struct {
+	Rate   bool
+	Pitch  bool
+	Volume bool
+}
+
+// ProsodyTTS 可选接口：能直接调整韵律的引擎，未声明支持的参数在解码时由ffmpeg调整
+type ProsodyTTS interface {
+	Prosody() ProsodySupport
+}
+
+// TTSVoice 引擎提供的发音人
+type TTSVoice struct {
+	ID       string `json:"id"` // 请求中 voice 字段的取值
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+	Gender   string `json:"gender,omitempty"`
+}
+
+// VoiceLister 可选接口：能列出发音人的引擎
+type VoiceLister interface {
+	Voices(ctx context.Context) ([]TTSVoice, error)
+}
+
+// TTSVoices 列出引擎的发音人，provider为空时列出所有引擎；不支持列出的引擎返回空列表
+func (s *AudioService) TTSVoices(ctx context.Context, provider string) (map[string][]TTSVoice, error) {
+	names := s.Registry.TTSNames()
+	if provider != "" {
+		if _, err := s.Registry.TTS(provider); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+		}
+		names = []string{provider}
+	}
+	result := make(map[string][]TTSVoice, len(names))
+	for _, name := range names {
+		p, _ := s.Registry.TTS(name)
+		voices := []TTSVoice{}
+		if vl, ok := p.(VoiceLister); ok {
+			list, err := vl.Voices(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 读取%s发音人失败: %v", ErrTTSProvider, name, err)
+			}
+			voices = list
+		}
+		result[name] = voices
+	}
+	return result, nil
+}
+
+// TextToSilk 合成语音并转换为SILK，合成与转换共用一个转换槽位
+// 文本按韵律和句子切分为多个片段分别合成，解码后拼接为一个WAV文件再进入转换流水线，
+// 单次提交给引擎的文本不超过 TTSChunkChars
+func (s *AudioService) TextToSilk(ctx context.Context, req TTSRequest, opts ConvertOptions) (*ConvertResult, error) {
+	provider, err := s.ttsProvider(req)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := req.segments()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ValidateConvertOptions(opts); err != nil {
+		return nil, err
+	}
+	decoderName := opts.Decoder
+	if decoderName == "" {
+		decoderName = "ffmpeg"
+	}
+	decoder, err := s.Registry.Decoder(decoderName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.release()
+
+	utils.Info("开始语音合成: 引擎=%s, 发音人=%q, 文本长度=%d, 分段=%d",
+		provider.Name(), req.Voice, utf8.RuneCountInString(req.Text), len(segments))
+	ttsCtx, cancel := stageContext(ctx, s.TTSTimeout)
+	defer cancel()
+	path, err := s.synthesize(ttsCtx, provider, decoder, req.Voice, segments, s.pcmFormat(opts.SilkOptions))
+	if err := stageError(ctx, ttsCtx, err, ErrTTSTimeout); err != nil {
+		return nil, fmt.Errorf("语音合成失败: %w", err)
+	}
+	utils.Info("%s语音合成完成", provider.Name())
+	return s.convert(ctx, UploadInput{Path: path}, opts)
+}
+
+// ttsProvider 检查请求并返回所选引擎
+func (s *AudioService) ttsProvider(req TTSRequest) (TTSProvider, error) {
+	maxChars := s.MaxTTSChars
+	if maxChars <= 0 {
+		maxChars = DefaultMaxTTSChars
+	}
+	if err := req.validate(maxChars); err != nil {
+		return nil, err
+	}
+
+	name := req.Provider
+	if name == "" {
+		name = s.DefaultTTS
+	}
+	if name == "" {
+		names := s.Registry.TTSNames()
+		if len(names) == 0 {
+			return nil, ErrTTSUnavailable
+		}
+		name = names[0]
+	}
+	provider, err := s.Registry.TTS(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	return provider, nil
+}
+
+// synthesize 逐段合成并解码为PCM，按SSML中的停顿插入静音，写入上传目录下的WAV文件
+func (s *AudioService) synthesize(ctx context.Context, provider TTSProvider, decoder Decoder, voice string, segments []ttsSegment, format PCMFormat) (string, error) {
+	chunkChars := s.TTSChunkChars
+	if chunkChars <= 0 {
+		chunkChars = DefaultTTSChunkChars
+	}
+	var support ProsodySupport
+	if pt, ok := provider.(ProsodyTTS); ok {
+		support = pt.Prosody()
+	}
+
+	path := s.UploadPath(".wav")
+	bytesPerSecond := format.SampleRate * format.Channels * 2
+	n, err := writeWAVFile(path, format, func(out io.Writer) error {
+		for _, seg := range segments {
+			if seg.Pause > 0 {
+				silence := int(seg.Pause.Seconds()*float64(bytesPerSecond)) / 2 * 2
+				if _, err := out.Write(make([]byte, silence)); err != nil {
+					return err
+				}
+			}
+			req, filters := splitProsody(seg.Prosody, support)
+			req.Voice = voice
+			if err := checkDecoderFilters(decoder, filters); err != nil {
+				return fmt.Errorf("%w（引擎%s不支持的韵律参数需要ffmpeg处理）", err, provider.Name())
+			}
+			for _, chunk := range chunkText(seg.Text, chunkChars) {
+				req.Text = chunk
+				if err := s.synthesizeChunk(ctx, provider, decoder, req, filters, format, out); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		os.Remove(path)
+		return "", fmt.Errorf("合成结果为空")
+	}
+	return path, nil
+}
+
+// synthesizeChunk 合成一个文本片段，解码为PCM后追加到w
+func (s *AudioService) synthesizeChunk(ctx context.Context, provider TTSProvider, decoder Decoder, req TTSRequest, filters FilterOptions, format PCMFormat, w io.Writer) error {
+	utils.Debug("合成片段: %d字", utf8.RuneCountInString(req.Text))
+	piece, err := s.storeInput(func(w io.Writer) error {
+		return provider.Synthesize(ctx, req, w)
+	})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(piece)
+	if err := decodeInput(ctx, decoder, piece, format, w, ConvertOptions{FilterOptions: filters}); err != nil {
+		return fmt.Errorf("解码合成结果失败: %w", err)
+	}
+	return nil
+}
+
+// splitProsody 将韵律分为交给引擎的参数和由ffmpeg实现的参数
+func splitProsody(p Prosody, support ProsodySupport) (TTSRequest, FilterOptions) {
+	var req TTSRequest
+	var filters FilterOptions
+	if p.Rate != 1 {
+		if support.Rate {
+			req.Rate = p.Rate
+		} else {
+			filters.Speed = p.Rate
+		}
+	}
+	if p.Pitch != 0 {
+		if support.Pitch {
+			req.Pitch = p.Pitch
+		} else {
+			filters.Pitch = p.Pitch
+		}
+	}
+	if p.Volume != 1 {
+		if support.Volume {
+			req.Volume = p.Volume
+		} else {
+			filters.Gain = math.Round(20*math.Log10(p.Volume)*100) / 100
+		}
+	}
+	return req, filters
+}
+
+// EspeakTTS 调用 espeak-ng 合成语音，输出WAV
+type EspeakTTS struct {
+	Path  string
+	Voice string // 默认发音人，如 cmn、en-us
+}
+
+// espeak-ng 的默认语速（词/分钟）、音调（0~99）和音量（0~200）
+const (
+	espeakRate   = 175
+	espeakPitch  = 50
+	espeakVolume = 100
+)
+
+// Name 返回引擎名称
+func (t *EspeakTTS) Name() string { return "espeak-ng" }
+
+// Prosody espeak-ng 可以直接调整语速、音调和音量
+func (t *EspeakTTS) Prosody() ProsodySupport {
+	return ProsodySupport{Rate: true, Pitch: true, Volume: true}
+}
+
+// Synthesize 文本通过stdin传入，避免以 - 开头的文本被当作参数
+// espeak-ng的音调参数没有单位，按每半音约4个单位换算
+func (t *EspeakTTS) Synthesize(ctx context.Context, req TTSRequest, w io.Writer) error {
+	args := []string{"--stdout"}
+	if voice := firstNonEmpty(req.Voice, t.Voice); voice != "" {
+		args = append(args, "-v", voice)
+	}
+	if req.Rate != 0 {
+		args = append(args, "-s", strconv.Itoa(int(math.Round(espeakRate*req.Rate))))
+	}
+	if req.Pitch != 0 {
+		pitch := int(math.Round(espeakPitch + req.Pitch*espeakPitch/MaxTTSPitch))
+		args = append(args, "-p", strconv.Itoa(clampInt(pitch, 0, 99)))
+	}
+	if req.Volume != 0 {
+		args = append(args, "-a", strconv.Itoa(int(math.Round(espeakVolume*req.Volume))))
+	}
+	args = append(args, "--stdin")
+	cmd := exec.CommandContext(ctx, t.Path, args...)
+	cmd.Stdin = strings.NewReader(req.Text)
+	cmd.Stdout = w
+	return runCommand("espeak-ng", cmd)
+}
+
+// Voices 解析 espeak-ng --voices 的输出：Pty Language Age/Gender VoiceName File Other Languages
+func (t *EspeakTTS) Voices(ctx context.Context) ([]TTSVoice, error) {
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.Path, "--voices")
+	cmd.Stdout = &stdout
+	if err := runCommand("espeak-ng", cmd); err != nil {
+		return nil, err
+	}
+	voices := []TTSVoice{}
+	for i, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 4 {
+			continue
+		}
+		voice := TTSVoice{ID: fields[1], Name: fields[3], Language: fields[1]}
+		switch {
+		case strings.HasSuffix(fields[2], "M"):
+			voice.Gender = "male"
+		case strings.HasSuffix(fields[2], "F"):
+			voice.Gender = "female"
+		}
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
+
+// PiperTTS 调用 piper 合成语音，piper只能输出到文件，先写入临时文件再复制
+type PiperTTS struct {
+	Path    string
+	Model   string // 模型文件(.onnx)，同目录下的 <模型>.json 记录说话人列表
+	TempDir string // 临时WAV文件目录
+}
+
+// piperConfig piper模型配置文件中需要的字段
+type piperConfig struct {
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+	SpeakerIDMap map[string]int `json:"speaker_id_map"`
+}
+
+// Name 返回引擎名称
+func (t *PiperTTS) Name() string { return "piper" }
+
+// Prosody piper 通过 --length_scale 调整语速
+func (t *PiperTTS) Prosody() ProsodySupport {
+	return ProsodySupport{Rate: true}
+}
+
+// config 读取模型配置文件
+func (t *PiperTTS) config() (*piperConfig, error) {
+	data, err := os.ReadFile(t.Model + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var cfg piperConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("解析piper模型配置失败: %v", err)
+	}
+	return &cfg, nil
+}
+
+// speaker 将voice解析为说话人编号，voice可以是编号或说话人名称
+func (t *PiperTTS) speaker(voice string) (string, error) {
+	if _, err := strconv.Atoi(voice); err == nil {
+		return voice, nil
+	}
+	cfg, err := t.config()
+	if err != nil {
+		return "", err
+	}
+	id, ok := cfg.SpeakerIDMap[voice]
+	if !ok {
+		return "", fmt.Errorf("%w: piper模型中没有说话人%q", ErrInvalidOptions, voice)
+	}
+	return strconv.Itoa(id), nil
+}
+
+// Synthesize 使用配置的模型合成语音，voice为多说话人模型的说话人编号或名称
+func (t *PiperTTS) Synthesize(ctx context.Context, req TTSRequest, w io.Writer) error {
+	args := []string{"--model", t.Model}
+	if req.Voice != "" {
+		speaker, err := t.speaker(req.Voice)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--speaker", speaker)
+	}
+	if req.Rate != 0 {
+		args = append(args, "--length_scale", strconv.FormatFloat(1/req.Rate, 'f', 3, 64))
+	}
+
+	tmp, err := os.CreateTemp(t.TempDir, ".piper-*.wav")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	cmd := exec.CommandContext(ctx, t.Path, append(args, "--output_file", tmpPath)...)
+	cmd.Stdin = strings.NewReader(req.Text)
+	if err := runCommand("piper", cmd); err != nil {
+		return err
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Voices 列出模型中的说话人，单说话人模型返回一个ID为空的默认发音人
+func (t *PiperTTS) Voices(ctx context.Context) ([]TTSVoice, error) {
+	name := strings.TrimSuffix(filepath.Base(t.Model), filepath.Ext(t.Model))
+	cfg, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.SpeakerIDMap) == 0 {
+		return []TTSVoice{{Name: name, Language: cfg.Language.Code}}, nil
+	}
+	voices := make([]TTSVoice, 0, len(cfg.SpeakerIDMap))
+	for speaker := range cfg.SpeakerIDMap {
+		voices = append(voices, TTSVoice{ID: speaker, Name: name + "/" + speaker, Language: cfg.Language.Code})
+	}
+	sort.Slice(voices, func(i, j int) bool { return voices[i].ID < voices[j].ID })
+	return voices, nil
+}
+
+// OpenAITTS 调用兼容OpenAI /v1/audio/speech 接口的HTTP服务
+type OpenAITTS struct {
+	BaseURL string // 服务地址，如 http://127.0.0.1:8880 或 http://127.0.0.1:8880/v1
+	APIKey  string
+	Model   string
+	Voice   string
+	Client  *http.Client // 为nil时使用不限制总时长的默认客户端，只受请求ctx约束
+}
+
+// NewOpenAITTS 创建OpenAI兼容TTS引擎，timeout限制单次请求（含读取音频）的总时长，0表示不限制
+func NewOpenAITTS(baseURL, apiKey, model, voice string, timeout time.Duration) *OpenAITTS {
+	return &OpenAITTS{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		Model:   model,
+		Voice:   voice,
+		Client:  newTTSClient(timeout),
+	}
+}
+
+// defaultTTSClient 未指定Client时使用的HTTP客户端
+var defaultTTSClient = newTTSClient(0)
+
+// newTTSClient 创建TTS服务专用的HTTP客户端，限制连接、握手、响应头的等待时间和空闲连接数
+func newTTSClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                  http.ProxyFromEnvironment,
+		DialContext:            dialer.DialContext,
+		ForceAttemptHTTP2:      true,
+		TLSHandshakeTimeout:    10 * time.Second,
+		MaxIdleConns:           4,
+		MaxIdleConnsPerHost:    4,
+		IdleConnTimeout:        90 * time.Second,
+		MaxResponseHeaderBytes: 64 << 10,
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// openAIVoices OpenAI的内置发音人，兼容服务通常沿用这些名称
+var openAIVoices = []string{"alloy", "echo", "fable", "onyx", "nova", "shimmer"}
+
+// Name 返回引擎名称
+func (t *OpenAITTS) Name() string { return "openai" }
+
+// Prosody 通过 speed 字段调整语速
+func (t *OpenAITTS) Prosody() ProsodySupport {
+	return ProsodySupport{Rate: true}
+}
+
+// Voices 返回OpenAI内置发音人和配置的默认发音人，接口本身不提供发音人列表
+func (t *OpenAITTS) Voices(ctx context.Context) ([]TTSVoice, error) {
+	names := openAIVoices
+	if t.Voice != "" && !containsString(names, t.Voice) {
+		names = append([]string{t.Voice}, names...)
+	}
+	voices := make([]TTSVoice, 0, len(names))
+	for _, name := range names {
+		voices = append(voices, TTSVoice{ID: name, Name: name})
+	}
+	return voices, nil
+}
+
+// endpoint 返回合成接口地址
+func (t *OpenAITTS) endpoint() string {
+	base := strings.TrimSuffix(t.BaseURL, "/")
+	if !strings.HasSuffix(base, "/v1") {
+		base += "/v1"
+	}
+	return base + "/audio/speech"
+}
+
+// Synthesize 请求WAV格式的语音
+func (t *OpenAITTS) Synthesize(ctx context.Context, req TTSRequest, w io.Writer) error {
+	params := map[string]interface{}{
+		"model":           t.Model,
+		"input":           req.Text,
+		"voice":           firstNonEmpty(req.Voice, t.Voice),
+		"response_format": "wav",
+	}
+	if req.Rate != 0 {
+		params["speed"] = req.Rate
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if t.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+t.APIKey)
+	}
+
+	client := t.Client
+	if client == nil {
+		client = defaultTTSClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTTSProvider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: HTTP %d: %s", ErrTTSProvider, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxTTSAudioBytes+1))
+	if err != nil {
+		return err
+	}
+	if n > maxTTSAudioBytes {
+		return fmt.Errorf("%w: 返回的音频超过%dMB", ErrTTSProvider, maxTTSAudioBytes>>20)
+	}
+	return nil
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -106,6 +107,17 @@ func SetLevel(level int) {
 	}
 }
 
+// ParseLevel 解析日志级别，支持名称（不区分大小写）或数字
+func ParseLevel(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	for level, name := range LevelNames {
+		if strings.EqualFold(value, name) || value == strconv.Itoa(level) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("无效的日志级别: %s", value)
+}
+
 // EnableColor 启用或禁用彩色输出
 func EnableColor(enable bool) {
 	if defaultLogger != nil {
@@ -260,14 +272,14 @@ func CloseLogger() {
 	}
 }
 
-// CleanOldLogs 清理旧日志文件（保留最近7天）
-func CleanOldLogs(logDir string) error {
+// CleanOldLogs 清理修改时间早于maxAge的日志文件
+func CleanOldLogs(logDir string, maxAge time.Duration) error {
 	entries, err := os.ReadDir(logDir)
 	if err != nil {
 		return fmt.Errorf("读取日志目录失败: %v", err)
 	}
 
-	cutoff := time.Now().AddDate(0, 0, -7)
+	cutoff := time.Now().Add(-maxAge)
 	var cleanedCount int
 
 	for _, entry := range entries {
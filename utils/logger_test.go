@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]int{
+		"DEBUG":  LevelDebug,
+		"info":   LevelInfo,
+		" Warn ": LevelWarn,
+		"ERROR":  LevelError,
+		"0":      LevelDebug,
+		"3":      LevelError,
+	}
+	for value, want := range tests {
+		if got, err := ParseLevel(value); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "TRACE", "9", "-1"} {
+		if _, err := ParseLevel(value); err == nil {
+			t.Errorf("ParseLevel(%q) 应返回错误", value)
+		}
+	}
+}
+
+func TestCleanOldLogs(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	files := map[string]time.Time{
+		"audio_converter_old.log": old,
+		"audio_converter_new.log": time.Now(),
+		"other.log":               old,
+	}
+	for name, mtime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CleanOldLogs(dir, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for name, kept := range map[string]bool{"audio_converter_old.log": false, "audio_converter_new.log": true, "other.log": true} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s: 存在 = %v, want %v", name, exists, kept)
+		}
+	}
+
+	if err := CleanOldLogs(filepath.Join(dir, "missing"), time.Hour); err == nil {
+		t.Error("日志目录不存在时应返回错误")
+	}
+}